@@ -0,0 +1,296 @@
+package qlog
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// access log formats of GinLoggerConfig.Format
+const (
+	// AccessFormatCommon is the Apache Common Log Format, also known as NCSA Common log format
+	AccessFormatCommon = `%h %l %u %t "%r" %>s %b`
+	// AccessFormatCombined is the Apache Combined Log Format
+	AccessFormatCombined = AccessFormatCommon + ` "%{Referer}i" "%{User-agent}i"`
+)
+
+const (
+	keyAccessEnabled      = "logger.access.enabled"
+	keyAccessPath         = "logger.access.path"
+	keyAccessName         = "logger.access.name"
+	keyAccessRotateTime   = "logger.access.rotate.time"
+	keyAccessRotateMaxAge = "logger.access.rotate.maxage"
+	keyAccessRotateCount  = "logger.access.rotate.count"
+
+	accessTimeFormat = "[02/Jan/2006:15:04:05 -0700]"
+)
+
+// accessDirective writes a part of an access log line
+type accessDirective func(b *bytes.Buffer, info *accessInfo)
+
+// accessFormat is a compiled access log template
+type accessFormat []accessDirective
+
+func literal(s string) accessDirective {
+	return func(b *bytes.Buffer, info *accessInfo) {
+		b.WriteString(s)
+	}
+}
+
+// orDash writes "-" for empty values as apache does
+func orDash(b *bytes.Buffer, s string) {
+	if len(s) == 0 {
+		b.WriteByte('-')
+		return
+	}
+	b.WriteString(s)
+}
+
+// escapeAccess writes s escaped as apache does for values from clients, " and \ are written as \" and \\,
+// control chars as \n, \t... or \xhh and non-ascii bytes as \xhh, so that clients can't forge fields or lines
+func escapeAccess(b *bytes.Buffer, s string) {
+	last := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= ' ' && c < 0x7f && c != '"' && c != '\\' {
+			continue
+		}
+
+		b.WriteString(s[last:i])
+		switch c {
+		case '"', '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\v':
+			b.WriteString(`\v`)
+		default:
+			b.WriteString(`\x`)
+			b.WriteByte(hexDigits[c>>4])
+			b.WriteByte(hexDigits[c&0xf])
+		}
+		last = i + 1
+	}
+	b.WriteString(s[last:])
+}
+
+// escapedOrDash is orDash for values from clients
+func escapedOrDash(b *bytes.Buffer, s string) {
+	if len(s) == 0 {
+		b.WriteByte('-')
+		return
+	}
+	escapeAccess(b, s)
+}
+
+var gAccessDirectives = map[byte]accessDirective{
+	'h': func(b *bytes.Buffer, info *accessInfo) { orDash(b, info.clientIP) },
+	'a': func(b *bytes.Buffer, info *accessInfo) { orDash(b, info.clientIP) },
+	'l': func(b *bytes.Buffer, info *accessInfo) { b.WriteByte('-') },
+	'u': func(b *bytes.Buffer, info *accessInfo) { escapedOrDash(b, info.user) },
+	't': func(b *bytes.Buffer, info *accessInfo) { b.WriteString(info.start.Format(accessTimeFormat)) },
+	'r': func(b *bytes.Buffer, info *accessInfo) {
+		escapeAccess(b, info.method)
+		b.WriteByte(' ')
+		escapeAccess(b, info.requestURI)
+		b.WriteByte(' ')
+		escapeAccess(b, info.proto)
+	},
+	's': func(b *bytes.Buffer, info *accessInfo) { b.WriteString(strconv.Itoa(info.status)) },
+	'b': func(b *bytes.Buffer, info *accessInfo) {
+		if info.dataLength == 0 {
+			b.WriteByte('-')
+			return
+		}
+		b.WriteString(strconv.Itoa(info.dataLength))
+	},
+	'B': func(b *bytes.Buffer, info *accessInfo) { b.WriteString(strconv.Itoa(info.dataLength)) },
+	'D': func(b *bytes.Buffer, info *accessInfo) {
+		b.WriteString(strconv.FormatInt(info.latency.Microseconds(), 10))
+	},
+	'T': func(b *bytes.Buffer, info *accessInfo) {
+		b.WriteString(strconv.FormatInt(int64(info.latency/time.Second), 10))
+	},
+	'm': func(b *bytes.Buffer, info *accessInfo) { escapeAccess(b, info.method) },
+	'U': func(b *bytes.Buffer, info *accessInfo) { escapeAccess(b, info.path) },
+	'q': func(b *bytes.Buffer, info *accessInfo) {
+		if len(info.query) > 0 {
+			b.WriteByte('?')
+			escapeAccess(b, info.query)
+		}
+	},
+	'H': func(b *bytes.Buffer, info *accessInfo) { escapeAccess(b, info.proto) },
+	'%': literal("%"),
+}
+
+// compileAccessFormat compiles an apache style template, "common", "ncsa" and "combined" are the predefined formats.
+// Supported directives are %h %a %l %u %t %r %s %>s %b %B %D %T %m %U %q %H %% and %{Name}i %{Name}o for
+// request and response headers, unknown directives are written as they are. %r is the request line with the raw
+// request uri, values from clients are escaped as apache does
+func compileAccessFormat(format string) accessFormat {
+	switch strings.ToLower(format) {
+	case "":
+		return nil
+	case "common", "ncsa":
+		format = AccessFormatCommon
+	case "combined":
+		format = AccessFormatCombined
+	}
+
+	var f accessFormat
+	for len(format) > 0 {
+		i := strings.IndexByte(format, '%')
+		if i < 0 || i == len(format)-1 {
+			f = append(f, literal(format))
+			break
+		}
+		if i > 0 {
+			f = append(f, literal(format[:i]))
+		}
+
+		directive := format[i : i+2]
+		format = format[i+1:]
+
+		// %>s is the final status, which is the only status known to middlewares
+		if strings.HasPrefix(format, ">") && len(format) > 1 {
+			format = format[1:]
+		}
+
+		if format[0] == '{' {
+			if end := strings.IndexByte(format, '}'); end > 0 && end < len(format)-1 {
+				name := format[1:end]
+				switch format[end+1] {
+				case 'i':
+					f = append(f, func(b *bytes.Buffer, info *accessInfo) { escapedOrDash(b, info.reqHeader.Get(name)) })
+					format = format[end+2:]
+					continue
+				case 'o':
+					f = append(f, func(b *bytes.Buffer, info *accessInfo) { escapedOrDash(b, info.rspHeader.Get(name)) })
+					format = format[end+2:]
+					continue
+				}
+			}
+		}
+
+		if d, ok := gAccessDirectives[format[0]]; ok {
+			f = append(f, d)
+		} else {
+			f = append(f, literal(directive))
+		}
+		format = format[1:]
+	}
+
+	return f
+}
+
+func (f accessFormat) render(info *accessInfo) string {
+	var b bytes.Buffer
+	for _, d := range f {
+		d(&b, info)
+	}
+	return b.String()
+}
+
+// accessLogConfig is the config of the dedicated access log file
+type accessLogConfig struct {
+	fullPath     string
+	rotateTime   time.Duration
+	rotateMaxAge time.Duration
+	rotateCount  uint
+}
+
+var (
+	gAccessMu     sync.Mutex
+	gAccessConfig accessLogConfig
+	gAccessWriter io.Writer // nil if the access log file is disabled
+)
+
+func getAccessLogConfig() (conf accessLogConfig, err error) {
+	if conf.fullPath, err = filepath.Abs(filepath.Join(v.GetString(keyAccessPath), v.GetString(keyAccessName))); err != nil {
+		return conf, err
+	}
+	if conf.rotateTime, err = time.ParseDuration(v.GetString(keyAccessRotateTime)); err != nil {
+		return conf, err
+	}
+	if conf.rotateMaxAge, err = time.ParseDuration(v.GetString(keyAccessRotateMaxAge)); err != nil {
+		return conf, err
+	}
+	conf.rotateCount = uint(v.GetInt(keyAccessRotateCount))
+	return conf, nil
+}
+
+// setupAccessLog opens the access log file by config, it is kept if the config is not changed
+func setupAccessLog() error {
+	gAccessMu.Lock()
+	defer gAccessMu.Unlock()
+
+	if !v.GetBool(keyAccessEnabled) {
+		closeAccessWriter()
+		return nil
+	}
+
+	conf, err := getAccessLogConfig()
+	if err != nil {
+		closeAccessWriter()
+		return fmt.Errorf("access log config fail:%s", err)
+	}
+	if gAccessWriter != nil && conf == gAccessConfig {
+		return nil
+	}
+
+	closeAccessWriter()
+	if gAccessWriter, err = newRotateWriter(conf.fullPath, conf.rotateTime, conf.rotateMaxAge, conf.rotateCount); err != nil {
+		return err
+	}
+	gAccessConfig = conf
+	return nil
+}
+
+func closeAccessWriter() {
+	if c, ok := gAccessWriter.(io.Closer); ok {
+		c.Close()
+	}
+	gAccessWriter = nil
+}
+
+// writeAccessLine writes a line to the access log file, it returns false if the file is disabled
+func writeAccessLine(line string) bool {
+	gAccessMu.Lock()
+	defer gAccessMu.Unlock()
+
+	if gAccessWriter == nil {
+		return false
+	}
+
+	if _, err := io.WriteString(gAccessWriter, line+"\n"); err != nil {
+		reportError(fmt.Errorf("write access log fail:%s", err))
+	}
+	return true
+}
+
+var _InitAccessLog = func() interface{} {
+	cli.Bool(keyAccessEnabled, false, "logger.access.enabled")
+	cli.String(keyAccessPath, ".", "logger.access.path")
+	cli.String(keyAccessName, "access.log", "logger.access.name")
+	cli.String(keyAccessRotateTime, "24h", "logger.access.rotate.time")
+	cli.String(keyAccessRotateMaxAge, "168h", "logger.access.rotate.maxage")
+	cli.String(keyAccessRotateCount, "0", "logger.access.rotate.count")
+
+	registerConfigKey(keyAccessRotateTime, checkDuration)
+	registerConfigKey(keyAccessRotateMaxAge, checkDuration)
+	registerConfigKey(keyAccessRotateCount, checkUint)
+
+	return nil
+}()
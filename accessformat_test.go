@@ -0,0 +1,107 @@
+package qlog
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func testAccessInfo() *accessInfo {
+	return &accessInfo{
+		start:      time.Date(2000, 10, 10, 13, 55, 36, 0, time.FixedZone("", -7*3600)),
+		method:     "GET",
+		path:       "/apache_pb.gif",
+		query:      "a=1",
+		requestURI: "/apache_pb.gif?a=1",
+		proto:      "HTTP/1.0",
+		user:       "frank",
+		status:     200,
+		latency:    1500 * time.Millisecond,
+		clientIP:   "127.0.0.1",
+		dataLength: 2326,
+		reqHeader: http.Header{
+			"Referer":    {"http://www.example.com/start.html"},
+			"User-Agent": {"Mozilla/4.08 [en] (Win98; I ;Nav)"},
+		},
+		rspHeader: http.Header{"Content-Type": {"image/gif"}},
+	}
+}
+
+func TestAccessFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		change func(info *accessInfo)
+		want   string
+	}{
+		{
+			name:   "common",
+			format: "common",
+			want:   `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif?a=1 HTTP/1.0" 200 2326`,
+		},
+		{
+			name:   "ncsa",
+			format: "NCSA",
+			want:   `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif?a=1 HTTP/1.0" 200 2326`,
+		},
+		{
+			name:   "combined",
+			format: "combined",
+			want: `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif?a=1 HTTP/1.0" 200 2326 ` +
+				`"http://www.example.com/start.html" "Mozilla/4.08 [en] (Win98; I ;Nav)"`,
+		},
+		{
+			name:   "combined without user, body and headers",
+			format: "combined",
+			change: func(info *accessInfo) {
+				info.user, info.dataLength, info.reqHeader = "", 0, http.Header{}
+			},
+			want: `127.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif?a=1 HTTP/1.0" 200 - "-" "-"`,
+		},
+		{
+			name:   "raw uri with quotes",
+			format: "combined",
+			change: func(info *accessInfo) {
+				info.requestURI = `/search?q="x" 200 1 "-" "evil"`
+				info.reqHeader.Set("User-Agent", "a\"b\\c\nd\xff")
+			},
+			want: `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /search?q=\"x\" 200 1 \"-\" \"evil\" HTTP/1.0" 200 2326 ` +
+				`"http://www.example.com/start.html" "a\"b\\c\nd\xff"`,
+		},
+		{
+			name:   "directives",
+			format: "%a %m %U%q %H %s %>s %B %D %T %{Content-Type}o %{X-Missing}i",
+			want:   "127.0.0.1 GET /apache_pb.gif?a=1 HTTP/1.0 200 200 2326 1500000 1 image/gif -",
+		},
+		{
+			name:   "empty query and zero length",
+			format: "%U%q %b %B",
+			change: func(info *accessInfo) { info.query, info.dataLength = "", 0 },
+			want:   "/apache_pb.gif - 0",
+		},
+		{
+			name:   "literals",
+			format: "100%% %z %{Name}x %{unclosed %",
+			want:   "100% %z %{Name}x %{unclosed %",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := testAccessInfo()
+			if tt.change != nil {
+				tt.change(info)
+			}
+
+			if got := compileAccessFormat(tt.format).render(info); got != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileAccessFormatEmpty(t *testing.T) {
+	if f := compileAccessFormat(""); f != nil {
+		t.Errorf("empty format is compiled to %d directives", len(f))
+	}
+}
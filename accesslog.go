@@ -0,0 +1,223 @@
+package qlog
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// default field names of access log entries, they can be renamed by GinLoggerConfig.FieldNames
+const (
+	FieldHostname   = "hostname"
+	FieldStatusCode = "statusCode"
+	FieldLatency    = "latency"
+	FieldClientIP   = "clientIP"
+	FieldMethod     = "method"
+	FieldPath       = "path"
+	FieldReferer    = "referer"
+	FieldDataLength = "dataLength"
+	FieldUserAgent  = "userAgent"
+	FieldRoute      = "route"
+	FieldReqHeaders = "reqHeaders"
+	FieldRspHeaders = "rspHeaders"
+	FieldSlow       = "slow"
+)
+
+// accessInfo is the information of a served request
+type accessInfo struct {
+	start      time.Time
+	method     string
+	path       string
+	query      string
+	requestURI string // raw request target of the request line
+	proto      string
+	user       string // user of basic auth
+	route      string // route template, empty if unknown
+	status     int
+	latency    time.Duration
+	clientIP   string
+	userAgent  string
+	referer    string
+	dataLength int
+	reqHeader  http.Header
+	rspHeader  http.Header
+	errMsg     string // errors of handlers, logged as message at error level if set
+}
+
+// requestURI returns the unmodified request target sent by the client, or the uri of the parsed url for requests
+// which are not from a server
+func requestURI(r *http.Request) string {
+	if len(r.RequestURI) > 0 {
+		return r.RequestURI
+	}
+	return r.URL.RequestURI()
+}
+
+// DefaultStatusLevel maps 5xx to error, 4xx to warn and others to trace
+func DefaultStatusLevel(status int) logrus.Level {
+	if status > 499 {
+		return logrus.ErrorLevel
+	} else if status > 399 {
+		return logrus.WarnLevel
+	}
+	return logrus.TraceLevel
+}
+
+// accessLogger logs requests by GinLoggerConfig, it is shared by gin and net/http middlewares
+type accessLogger struct {
+	conf     GinLoggerConfig
+	hostname string
+	skip     map[string]bool
+	format   accessFormat // nil if entries are not formatted as access log lines
+
+	routeCounts sync.Map // route -> *atomic.Uint64
+}
+
+func newAccessLogger(conf GinLoggerConfig) *accessLogger {
+	l := &accessLogger{
+		conf:     conf,
+		hostname: "unknown",
+		skip:     make(map[string]bool, len(conf.SkipPaths)),
+		format:   compileAccessFormat(conf.Format),
+	}
+
+	if h, err := os.Hostname(); err == nil {
+		l.hostname = h
+	}
+
+	for _, p := range conf.SkipPaths {
+		l.skip[p] = true
+	}
+
+	if l.conf.StatusLevel == nil {
+		l.conf.StatusLevel = DefaultStatusLevel
+	}
+
+	if l.conf.SlowLevel == 0 && !l.conf.SlowLevelSet {
+		l.conf.SlowLevel = logrus.WarnLevel
+	}
+
+	return l
+}
+
+// skipped checks if requests of path are not logged
+func (l *accessLogger) skipped(path string) bool {
+	if l.skip[path] {
+		return true
+	}
+
+	for _, re := range l.conf.SkipRegexps {
+		if re.MatchString(path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sampled checks if a request of route should be logged by RouteSampling
+func (l *accessLogger) sampled(route string) bool {
+	n, ok := l.conf.RouteSampling[route]
+	if !ok || n <= 1 {
+		return true
+	}
+
+	counter, _ := l.routeCounts.LoadOrStore(route, new(atomic.Uint64))
+	return (counter.(*atomic.Uint64).Add(1)-1)%n == 0
+}
+
+func (l *accessLogger) setField(fields logrus.Fields, name string, value interface{}) {
+	if newName, ok := l.conf.FieldNames[name]; ok {
+		if len(newName) == 0 {
+			return
+		}
+		name = newName
+	}
+	fields[name] = value
+}
+
+func selectHeaders(header http.Header, names []string) map[string]string {
+	values := make(map[string]string, len(names))
+	for _, name := range names {
+		if v := header.Get(name); len(v) > 0 {
+			values[name] = v
+		}
+	}
+	return values
+}
+
+// log logs a request, entry is the logger with request-scoped fields
+func (l *accessLogger) log(entry *logrus.Entry, info *accessInfo) {
+	latency := int(math.Ceil(float64(info.latency.Nanoseconds()) / 1000000.0))
+
+	level := l.conf.StatusLevel(info.status)
+	if len(info.errMsg) > 0 {
+		level = logrus.ErrorLevel
+	}
+
+	slow := l.conf.SlowThreshold > 0 && info.latency >= l.conf.SlowThreshold
+	if slow && level > l.conf.SlowLevel {
+		level = l.conf.SlowLevel
+	}
+
+	// only requests logged at info level or lower are sampled
+	if level > logrus.WarnLevel && !slow && !l.sampled(info.route) {
+		return
+	}
+
+	var line string
+	if l.format != nil {
+		// access log lines are written to the access log file if it is enabled, or logged as messages
+		if line = l.format.render(info); writeAccessLine(line) {
+			return
+		}
+	}
+
+	if !entry.Logger.IsLevelEnabled(level) {
+		return
+	}
+
+	fields := make(logrus.Fields, 12)
+	l.setField(fields, FieldHostname, l.hostname)
+	l.setField(fields, FieldStatusCode, info.status)
+	l.setField(fields, FieldLatency, latency) // time to process
+	l.setField(fields, FieldClientIP, info.clientIP)
+	l.setField(fields, FieldMethod, info.method)
+	l.setField(fields, FieldPath, info.path)
+	l.setField(fields, FieldReferer, info.referer)
+	l.setField(fields, FieldDataLength, info.dataLength)
+	l.setField(fields, FieldUserAgent, info.userAgent)
+
+	if l.conf.Route {
+		l.setField(fields, FieldRoute, info.route)
+	}
+	if len(l.conf.RequestHeaders) > 0 {
+		l.setField(fields, FieldReqHeaders, selectHeaders(info.reqHeader, l.conf.RequestHeaders))
+	}
+	if len(l.conf.ResponseHeaders) > 0 {
+		l.setField(fields, FieldRspHeaders, selectHeaders(info.rspHeader, l.conf.ResponseHeaders))
+	}
+	if slow {
+		l.setField(fields, FieldSlow, true)
+	}
+
+	entry = entry.WithFields(fields)
+
+	if len(line) > 0 {
+		entry.Log(level, line)
+		return
+	}
+
+	if len(info.errMsg) > 0 {
+		entry.Log(level, info.errMsg)
+		return
+	}
+
+	entry.Log(level, fmt.Sprintf("%d \"%s %s\" (%dms)", info.status, info.method, info.path, latency))
+}
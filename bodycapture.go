@@ -0,0 +1,186 @@
+package qlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// field names of captured bodies
+const (
+	FieldReqBody        = "reqBody"
+	FieldRspBody        = "rspBody"
+	FieldReqLength      = "reqLength"
+	FieldRspLength      = "rspLength"
+	FieldReqContentType = "reqContentType"
+	FieldRspContentType = "rspContentType"
+)
+
+const (
+	defaultMaxBodySize = 16 * 1024
+)
+
+// media types captured by default
+var gDefaultBodyContentTypes = []string{"application/json", "application/x-www-form-urlencoded"}
+
+// media types never captured, their bodies are large, binary or never end
+var gUncapturedContentTypes = []string{
+	"multipart/*",
+	"text/event-stream",
+	"application/octet-stream",
+	"image/*",
+	"audio/*",
+	"video/*",
+}
+
+// BodyCaptureConfig limits request and response bodies captured by api loggers
+type BodyCaptureConfig struct {
+	// MaxBodySize is the max bytes captured of each body, longer bodies are truncated, default is 16KB
+	MaxBodySize int
+	// ContentTypes are media types of captured bodies, "text/*" matches all text types, "*/*" matches all,
+	// default is application/json and application/x-www-form-urlencoded.
+	// multipart, event stream and binary bodies, and bodies with Content-Encoding are never captured
+	ContentTypes []string
+}
+
+func (conf BodyCaptureConfig) withDefaults() BodyCaptureConfig {
+	if conf.MaxBodySize <= 0 {
+		conf.MaxBodySize = defaultMaxBodySize
+	}
+	if len(conf.ContentTypes) == 0 {
+		conf.ContentTypes = gDefaultBodyContentTypes
+	}
+	return conf
+}
+
+// mediaType returns the lowercase media type of a Content-Type value without parameters
+func mediaType(contentType string) string {
+	if t, _, err := mime.ParseMediaType(contentType); err == nil {
+		return t
+	}
+	t, _, _ := strings.Cut(contentType, ";")
+	return strings.ToLower(strings.TrimSpace(t))
+}
+
+func matchMediaType(t string, patterns []string) bool {
+	for _, p := range patterns {
+		if p == t || p == "*/*" || (strings.HasSuffix(p, "/*") && strings.HasPrefix(t, p[:len(p)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+func isJSONMediaType(t string) bool {
+	return t == "application/json" || strings.HasSuffix(t, "+json")
+}
+
+// bodyCapture keeps the first MaxBodySize bytes of a body and counts its length,
+// whether the body is captured is decided by headers at the first write
+type bodyCapture struct {
+	conf    BodyCaptureConfig
+	header  http.Header
+	decided bool
+	enabled bool
+	buf     bytes.Buffer
+	total   int
+}
+
+func newBodyCapture(conf BodyCaptureConfig, header http.Header) *bodyCapture {
+	return &bodyCapture{conf: conf, header: header}
+}
+
+func (b *bodyCapture) decide() {
+	if b.decided {
+		return
+	}
+	b.decided = true
+
+	if enc := b.header.Get("Content-Encoding"); len(enc) > 0 && enc != "identity" {
+		return
+	}
+
+	t := mediaType(b.header.Get("Content-Type"))
+	b.enabled = !matchMediaType(t, gUncapturedContentTypes) && matchMediaType(t, b.conf.ContentTypes)
+}
+
+// room returns the bytes can be captured of n more bytes
+func (b *bodyCapture) room(n int) int {
+	b.decide()
+	b.total += n
+
+	if !b.enabled {
+		return 0
+	}
+	if room := b.conf.MaxBodySize - b.buf.Len(); room < n {
+		return room
+	}
+	return n
+}
+
+func (b *bodyCapture) Write(p []byte) (int, error) {
+	b.buf.Write(p[:b.room(len(p))])
+	return len(p), nil
+}
+
+func (b *bodyCapture) WriteString(s string) (int, error) {
+	b.buf.WriteString(s[:b.room(len(s))])
+	return len(s), nil
+}
+
+// stop drops the captured bytes, it is called when a body is streamed
+func (b *bodyCapture) stop() {
+	b.decided = true
+	b.enabled = false
+	b.buf = bytes.Buffer{}
+}
+
+// value returns the captured body, json bodies are parsed if not truncated
+func (b *bodyCapture) value() interface{} {
+	if b.total > b.buf.Len() {
+		return b.buf.String() + "..."
+	}
+
+	if isJSONMediaType(mediaType(b.header.Get("Content-Type"))) {
+		var v interface{}
+		if err := json.Unmarshal(b.buf.Bytes(), &v); err == nil {
+			return v
+		}
+	}
+
+	return b.buf.String()
+}
+
+// setFields sets body, length and content type fields, length is the body length if known or -1
+func (b *bodyCapture) setFields(fields logrus.Fields, bodyKey, lengthKey, typeKey string, length int) {
+	if length < b.total {
+		length = b.total
+	}
+	if length <= 0 {
+		return
+	}
+
+	fields[lengthKey] = length
+	if b.enabled && b.buf.Len() > 0 {
+		fields[bodyKey] = b.value()
+	} else {
+		fields[typeKey] = b.header.Get("Content-Type")
+	}
+}
+
+// captureReadCloser captures bytes read from a request body
+type captureReadCloser struct {
+	io.ReadCloser
+	body *bodyCapture
+}
+
+func (r *captureReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.body.Write(p[:n])
+	return n, err
+}
@@ -0,0 +1,71 @@
+package qlog
+
+import (
+	"context"
+	"runtime"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	keyCallerSkip = "logger.caller.skip"
+)
+
+// funcSkipped checks if function is one of skips, or in one of package prefixes of skips,
+// e.g. "github.com/a/b" skips all functions of package b and its sub packages, "main.logError" skips only logError
+// and its closures
+func funcSkipped(function string, skips []string) bool {
+	for _, skip := range skips {
+		if function == skip {
+			return true
+		}
+	}
+	return funcInPackages(function, skips)
+}
+
+// skippedCaller returns the first frame of the calling stack which is not in skips, after n more frames are skipped
+func skippedCaller(skips []string, n int) *runtime.Frame {
+	pcs := make([]uintptr, maxStackDepth)
+	num := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:num])
+
+	for {
+		frame, more := frames.Next()
+		if !funcSkipped(frame.Function, skips) {
+			if n <= 0 {
+				return &frame
+			}
+			n--
+		}
+		if !more {
+			return nil
+		}
+	}
+}
+
+// WithCallerSkip returns an entry whose caller skips n more frames, it is used by logging helpers
+// to report their callers, e.g. WithCallerSkip(entry, 1).Error(msg) in a helper reports the caller of the helper.
+// Skips are carried by the entry context, they are accumulated by nested helpers and lost if the context is replaced.
+func WithCallerSkip(entry *logrus.Entry, n int) *logrus.Entry {
+	ctx := entry.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	skip, _ := ctx.Value(ctxKeyCallerSkip).(int)
+	return entry.WithContext(context.WithValue(ctx, ctxKeyCallerSkip, skip+n))
+}
+
+// getCallerSkips returns frames skipped when looking for the caller of an entry, nil if there is no config
+func getCallerSkips() []string {
+	skips := v.GetStringSlice(keyCallerSkip)
+	if len(skips) == 0 {
+		return nil
+	}
+	return append(append([]string{}, gEntrySkipPkgs...), skips...)
+}
+
+var _InitCaller = func() interface{} {
+	registerConfigKey(keyCallerSkip, nil)
+	return nil
+}()
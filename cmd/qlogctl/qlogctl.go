@@ -0,0 +1,79 @@
+// qlogctl is the command line tool of qlog, it loads logger config the same way as apps using qlog
+//
+//	qlogctl config check [--logger.xxx=...]  report unknown keys and invalid values
+//	qlogctl config dump  [--logger.xxx=...]  print the effective config and the source of each value
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kkkbird/qlog"
+	// loads the config without configuring the logger, it must be initialized before qlog
+	_ "github.com/kkkbird/qlog/configonly"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s config check|dump [--logger.xxx=...]\n", os.Args[0])
+	os.Exit(2)
+}
+
+func configCheck() int {
+	errs := qlog.Validate()
+	if len(errs) == 0 {
+		fmt.Println("config ok")
+		return 0
+	}
+
+	for _, err := range errs {
+		fmt.Println(err)
+	}
+	return 1
+}
+
+func configDump() int {
+	configFile := qlog.ConfigFileUsed()
+	if configFile == "" {
+		configFile = "<none>"
+	}
+	fmt.Printf("# config file: %s\n", configFile)
+
+	values := qlog.EffectiveConfig()
+
+	width := 0
+	for _, cv := range values {
+		if len(cv.Key) > width {
+			width = len(cv.Key)
+		}
+	}
+
+	for _, cv := range values {
+		fmt.Printf("%-*s = %-24v # %s\n", width, cv.Key, formatValue(cv.Value), cv.Source)
+	}
+	return 0
+}
+
+func formatValue(value interface{}) string {
+	if s, ok := value.([]string); ok {
+		return "[" + strings.Join(s, ",") + "]"
+	}
+	return fmt.Sprint(value)
+}
+
+func main() {
+	args := qlog.FilterFlags(os.Args[1:])
+
+	if len(args) != 2 || args[0] != "config" {
+		usage()
+	}
+
+	switch args[1] {
+	case "check":
+		os.Exit(configCheck())
+	case "dump":
+		os.Exit(configDump())
+	default:
+		usage()
+	}
+}
@@ -0,0 +1,14 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/kkkbird/qlog"
+)
+
+// TestConfigOnly checks qlog loads config only in qlogctl, no hook is configured
+func TestConfigOnly(t *testing.T) {
+	if hooks := qlog.GetLevelConfig().Hooks; len(hooks) != 0 {
+		t.Errorf("logger is configured in qlogctl, hooks: %v", hooks)
+	}
+}
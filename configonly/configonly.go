@@ -0,0 +1,18 @@
+// Package configonly makes qlog load the logger config without configuring the logger, it is imported by tools
+// checking config such as qlogctl:
+//
+//	import _ "github.com/kkkbird/qlog/configonly"
+//
+// It sets QLOG_CONFIG_ONLY=1 when it is initialized, which is before qlog is initialized as qlog depends on
+// packages whose import paths sort after it, e.g. github.com/sirupsen/logrus. With QLOG_CONFIG_ONLY=1 qlog doesn't
+// configure hooks or watch the config file, so no log file is created and no connection is opened.
+package configonly
+
+import "os"
+
+// EnvConfigOnly is the env checked by qlog when it is initialized
+const EnvConfigOnly = "QLOG_CONFIG_ONLY"
+
+func init() {
+	os.Setenv(EnvConfigOnly, "1")
+}
@@ -0,0 +1,130 @@
+package qlog
+
+import (
+	"context"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// ginKeyEntry is the key of the request-scoped entry in gin.Context
+	ginKeyEntry = "qlog.entry"
+
+	keyContextKeys = "logger.context.keys"
+)
+
+// NewContext returns a copy of ctx carrying entry, FromContext(ctx) returns it
+func NewContext(ctx context.Context, entry *logrus.Entry) context.Context {
+	return context.WithValue(ctx, ctxKeyEntry, entry)
+}
+
+// contextEntry returns the entry carried by ctx or a *gin.Context
+func contextEntry(ctx context.Context) (*logrus.Entry, bool) {
+	if entry, ok := ctx.Value(ctxKeyEntry).(*logrus.Entry); ok {
+		return entry, true
+	}
+
+	// gin.Context doesn't look up request context for non-string keys by default
+	if c, ok := ctx.Value(gin.ContextKey).(*gin.Context); ok {
+		if entry, ok := c.Value(ginKeyEntry).(*logrus.Entry); ok {
+			return entry, true
+		}
+	}
+
+	return nil, false
+}
+
+// FromContext returns the entry carried by ctx or a *gin.Context, e.g. the request-scoped entry set by GinRequestID,
+// an entry of qlog default logger is returned if there is none. The returned entry carries ctx.
+func FromContext(ctx context.Context) *logrus.Entry {
+	if ctx == nil {
+		return logrus.NewEntry(qLogger)
+	}
+
+	if entry, ok := contextEntry(ctx); ok {
+		return entry.WithContext(ctx)
+	}
+
+	return logrus.NewEntry(qLogger).WithContext(ctx)
+}
+
+// WithContext returns an entry of qlog default logger carrying ctx, fields of ctx are added when it is logged
+func WithContext(ctx context.Context) *logrus.Entry {
+	return logrus.NewEntry(qLogger).WithContext(ctx)
+}
+
+// ContextWithFields returns a copy of ctx carrying the entry of ctx with fields added,
+// it accumulates fields of a request through function calls
+func ContextWithFields(ctx context.Context, fields logrus.Fields) context.Context {
+	return NewContext(ctx, FromContext(ctx).WithFields(fields))
+}
+
+// ContextExtractor returns fields of values carried by ctx
+type ContextExtractor func(ctx context.Context) logrus.Fields
+
+var (
+	gContextMu         sync.RWMutex
+	gContextExtractors []ContextExtractor
+)
+
+// RegisterContextExtractor registers an extractor, fields it returns are added to entries carrying a context
+// when they are logged, fields set in entries are not overwritten
+func RegisterContextExtractor(extract ContextExtractor) {
+	gContextMu.Lock()
+	defer gContextMu.Unlock()
+
+	gContextExtractors = append(gContextExtractors, extract)
+}
+
+// RegisterContextKey registers a context key, e.g. an unexported key type of tenant,
+// its value is added to entries carrying a context as field name
+func RegisterContextKey(name string, key interface{}) {
+	RegisterContextExtractor(func(ctx context.Context) logrus.Fields {
+		if value := ctx.Value(key); value != nil {
+			return logrus.Fields{name: value}
+		}
+		return nil
+	})
+}
+
+// setFields sets fields not set in e
+func setFields(e *logrus.Entry, fields logrus.Fields) {
+	for k, v := range fields {
+		if _, ok := e.Data[k]; !ok {
+			e.Data[k] = v
+		}
+	}
+}
+
+// extractContextFields adds fields of the entry carried by e.Context, values of string keys configured
+// by logger.context.keys and fields returned by registered extractors to e
+func extractContextFields(e *logrus.Entry, keys []string) {
+	ctx := e.Context
+
+	if entry, ok := contextEntry(ctx); ok {
+		setFields(e, entry.Data)
+	}
+
+	for _, key := range keys {
+		if _, ok := e.Data[key]; ok {
+			continue
+		}
+		if value := ctx.Value(key); value != nil {
+			e.Data[key] = value
+		}
+	}
+
+	gContextMu.RLock()
+	defer gContextMu.RUnlock()
+
+	for _, extract := range gContextExtractors {
+		setFields(e, extract(ctx))
+	}
+}
+
+var _InitContext = func() interface{} {
+	registerConfigKey(keyContextKeys, nil)
+	return nil
+}()
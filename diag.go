@@ -0,0 +1,149 @@
+package qlog
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HookStatus is the health of a registered hook
+type HookStatus struct {
+	Name          string
+	Enabled       bool      // enabled in config
+	Active        bool      // set up successfully and fired by logger
+	Healthy       bool      // active and the last setup or fire succeeded
+	Errors        uint64    // number of setup and fire errors
+	LastError     string    // empty if no error
+	LastErrorTime time.Time // zero if no error
+}
+
+// hookState keeps the status of a hook across config reloads
+type hookState struct {
+	name    string
+	enabled atomic.Bool
+	active  atomic.Bool
+	failing atomic.Bool
+	errors  atomic.Uint64
+
+	// metrics
+	fired        atomic.Uint64 // entries fired to the hook
+	failed       atomic.Uint64 // fire errors
+	dropped      atomic.Uint64 // entries not written at all, by formatter or write errors
+	bytes        atomic.Uint64 // bytes written
+	formatErrors atomic.Uint64 // formatter errors
+
+	mu            sync.Mutex
+	lastError     error
+	lastErrorTime time.Time
+}
+
+var (
+	gErrorCount   atomic.Uint64
+	gErrorHandler atomic.Pointer[func(error)]
+
+	// diagnostics are written to gDiagWriter if no error handler is set, never to stdout
+	gDiagWriter io.Writer = os.Stderr
+	gDiagMu     sync.Mutex
+
+	gHookStates   = make(map[string]*hookState)
+	gHookStatesMu sync.Mutex
+)
+
+// SetErrorHandler sets the handler of qlog internal errors, e.g. hook setup, hook fire and config reload failures.
+// h must not log through qlog, errors are written to stderr if h is nil.
+func SetErrorHandler(h func(err error)) {
+	if h == nil {
+		gErrorHandler.Store(nil)
+		return
+	}
+	gErrorHandler.Store(&h)
+}
+
+// ErrorCount returns the number of qlog internal errors
+func ErrorCount() uint64 {
+	return gErrorCount.Load()
+}
+
+func reportError(err error) {
+	gErrorCount.Add(1)
+
+	if h := gErrorHandler.Load(); h != nil {
+		(*h)(err)
+		return
+	}
+
+	diagf("%s", err)
+}
+
+// diagf writes a diagnostic message to gDiagWriter
+func diagf(format string, args ...interface{}) {
+	gDiagMu.Lock()
+	defer gDiagMu.Unlock()
+
+	fmt.Fprintf(gDiagWriter, "[qlog] "+format+"\n", args...)
+}
+
+func getHookState(name string) *hookState {
+	gHookStatesMu.Lock()
+	defer gHookStatesMu.Unlock()
+
+	s, ok := gHookStates[name]
+	if !ok {
+		s = &hookState{name: name}
+		gHookStates[name] = s
+	}
+	return s
+}
+
+// fail records and reports a hook error
+func (s *hookState) fail(err error) {
+	s.failing.Store(true)
+	s.errors.Add(1)
+
+	s.mu.Lock()
+	s.lastError = err
+	s.lastErrorTime = time.Now()
+	s.mu.Unlock()
+
+	reportError(fmt.Errorf("hook(%s): %s", s.name, err))
+}
+
+func (s *hookState) succeed() {
+	if s.failing.Load() {
+		s.failing.Store(false)
+	}
+}
+
+func (s *hookState) status() HookStatus {
+	st := HookStatus{
+		Name:    s.name,
+		Enabled: s.enabled.Load(),
+		Active:  s.active.Load(),
+		Errors:  s.errors.Load(),
+	}
+	st.Healthy = st.Active && !s.failing.Load()
+
+	s.mu.Lock()
+	if s.lastError != nil {
+		st.LastError = s.lastError.Error()
+		st.LastErrorTime = s.lastErrorTime
+	}
+	s.mu.Unlock()
+
+	return st
+}
+
+// Status returns the health of all registered hooks
+func Status() []HookStatus {
+	names := registeredHookNames()
+	status := make([]HookStatus, 0, len(names))
+
+	for _, name := range names {
+		status = append(status, getHookState(name).status())
+	}
+
+	return status
+}
@@ -0,0 +1,209 @@
+package qlog
+
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+type ctxKey int
+
+const (
+	ctxKeyCaller     ctxKey = iota // *runtime.Frame, the real caller of a redirected log
+	ctxKeyEntry                    // *logrus.Entry, the entry carried by context
+	ctxKeyCallerSkip               // int, frames skipped by WithCallerSkip
+)
+
+// withCaller records the real caller in ctx, dispatchHook will use it as entry caller
+func withCaller(ctx context.Context, caller *runtime.Frame) context.Context {
+	return context.WithValue(ctx, ctxKeyCaller, caller)
+}
+
+// callerFromPC returns the frame of a pc returned by runtime.Callers
+func callerFromPC(pc uintptr) *runtime.Frame {
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	return &frame
+}
+
+const (
+	levelInherit = -1 // hook level is not set, entries allowed by levelFilter are all fired
+)
+
+// hookEntry is an activated hook with its runtime level
+type hookEntry struct {
+	name  string
+	hook  logrus.Hook
+	state *hookState
+	level atomic.Int32 // logrus.Level or levelInherit
+}
+
+func newHookEntry(state *hookState, hook logrus.Hook) *hookEntry {
+	name := state.name
+	h := &hookEntry{name: name, hook: hook, state: state}
+	h.level.Store(levelInherit)
+
+	if level, ok, err := getHookLevel(name); err == nil && ok {
+		h.level.Store(int32(level))
+	}
+
+	return h
+}
+
+// enabled checks if entries of level should be fired to the hook, as getLogLevels(), panic entries are never fired
+func (h *hookEntry) enabled(level logrus.Level) bool {
+	l := h.level.Load()
+	return level > logrus.PanicLevel && (l == levelInherit || level <= logrus.Level(l))
+}
+
+// levelString returns the hook level, empty if it is not set
+func (h *hookEntry) levelString() string {
+	if l := h.level.Load(); l != levelInherit {
+		return logrus.Level(l).String()
+	}
+	return ""
+}
+
+// packages skipped when looking for the caller of an entry
+var gEntrySkipPkgs = []string{"github.com/sirupsen/logrus", gPkgPath, "log", "log/slog"}
+
+// entryCaller returns the caller of an entry even if reportcaller is disabled, the caller is kept in e.Caller,
+// which is not used by formatters if reportcaller is disabled. It walks the stack, so it is only called when the
+// caller is needed, skips are frames skipped as dispatchHook.callerSkips.
+func entryCaller(e *logrus.Entry, skips []string) *runtime.Frame {
+	if e.Caller == nil {
+		skip := 0
+		if e.Context != nil {
+			skip, _ = e.Context.Value(ctxKeyCallerSkip).(int)
+		}
+		if skips == nil {
+			skips = gEntrySkipPkgs
+		}
+		e.Caller = skippedCaller(skips, skip)
+	}
+	return e.Caller
+}
+
+// dispatchHook is the only hook qlog installs into logrus, it prepares and filters the entry once
+// and then fires all activated hooks enabled for the entry level
+type dispatchHook struct {
+	hooks    []*hookEntry
+	filter   *levelFilter
+	sampler  *sampler       // nil if sampling is not configured
+	redactor *redactor      // nil if redaction is not configured
+	expander *errorExpander // nil if errors are not expanded
+
+	contextKeys []string // string keys of context values added to entries
+	callerSkips []string // frames skipped when looking for the caller, nil if skips are not configured
+}
+
+func newDispatchHook(hooks []*hookEntry, filter *levelFilter, sampler *sampler, redactor *redactor, expander *errorExpander, contextKeys []string, callerSkips []string) *dispatchHook {
+	d := &dispatchHook{hooks: hooks, filter: filter, sampler: sampler, redactor: redactor, expander: expander,
+		contextKeys: contextKeys, callerSkips: callerSkips}
+
+	if sampler != nil {
+		sampler.start(d.fireHooks)
+	}
+	return d
+}
+
+func (d *dispatchHook) getHook(name string) *hookEntry {
+	for _, h := range d.hooks {
+		if h.name == name {
+			return h
+		}
+	}
+	return nil
+}
+
+// Levels return all levels, level filter is done by levelFilter and hook levels
+func (d *dispatchHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire prepares and filters the entry and fires activated hooks, a failed hook won't stop the others.
+// Hook errors are reported by hookState, so logrus won't print them.
+func (d *dispatchHook) Fire(e *logrus.Entry) error {
+	d.prepare(e)
+
+	if !d.filter.allowed(e, d.callerSkips) {
+		return nil
+	}
+
+	if d.sampler != nil && !d.sampler.allowed(e, d.callerSkips) {
+		return nil
+	}
+
+	if d.expander != nil {
+		d.expander.expandEntry(e)
+	}
+
+	if d.redactor != nil {
+		d.redactor.redact(e)
+	}
+
+	d.fireHooks(e)
+	return nil
+}
+
+// fireHooks fires activated hooks enabled for the entry level
+func (d *dispatchHook) fireHooks(e *logrus.Entry) {
+	gLevelCounts[e.Level].Add(1)
+
+	for _, h := range d.hooks {
+		if !h.enabled(e.Level) {
+			continue
+		}
+		h.state.fired.Add(1)
+		if err := h.hook.Fire(e); err != nil {
+			h.state.failed.Add(1)
+			h.state.fail(err)
+		} else {
+			h.state.succeed()
+		}
+	}
+}
+
+// stderrHook expands errors and redacts entries which logrus writes to stderr when no hook is activated
+type stderrHook struct {
+	redactor *redactor      // nil if redaction is not configured
+	expander *errorExpander // nil if errors are not expanded
+}
+
+func (h *stderrHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire changes the entry in place, logrus writes it after hooks fire
+func (h *stderrHook) Fire(e *logrus.Entry) error {
+	if h.expander != nil {
+		h.expander.expandEntry(e)
+	}
+	if h.redactor != nil {
+		h.redactor.redact(e)
+	}
+	return nil
+}
+
+// prepare sets the real caller and adds fields of the context carried by e
+func (d *dispatchHook) prepare(e *logrus.Entry) {
+	skip := 0
+	if e.Context != nil {
+		if caller, ok := e.Context.Value(ctxKeyCaller).(*runtime.Frame); ok {
+			e.Caller = caller
+			skip = -1 // the real caller is known
+		} else {
+			skip, _ = e.Context.Value(ctxKeyCallerSkip).(int)
+		}
+
+		extractContextFields(e, d.contextKeys)
+	}
+
+	// logrus reports the first frame outside logrus if reportcaller is enabled, look for the caller again if it should
+	// be skipped. Otherwise e.Caller is nil and it is looked up by entryCaller only if it is needed.
+	if e.Caller != nil && (skip > 0 || (skip == 0 && d.callerSkips != nil && funcSkipped(e.Caller.Function, d.callerSkips))) {
+		e.Caller = nil
+		entryCaller(e, d.callerSkips)
+	}
+}
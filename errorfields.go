@@ -0,0 +1,179 @@
+package qlog
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// field names of expanded errors
+const (
+	FieldErrorType  = "errorType"
+	FieldErrorChain = "errorChain"
+	FieldErrorStack = "errorStack"
+)
+
+const (
+	keyErrorsExpand     = "logger.errors.expand"
+	keyErrorsStackLevel = "logger.errors.stacklevel"
+)
+
+// Stack is a stack trace, it is rendered in one line by String, and as an array of frames by json
+type Stack []StackFrame
+
+func (s Stack) String() string {
+	frames := make([]string, len(s))
+	for i, f := range s {
+		frames[i] = f.String()
+	}
+	return strings.Join(frames, "; ")
+}
+
+// ErrorChain is messages of errors wrapped by an error, in depth-first order of errors.Unwrap and errors.Join
+type ErrorChain []string
+
+func (c ErrorChain) String() string {
+	return strings.Join(c, "; ")
+}
+
+// stackTracer is implemented by errors of github.com/pkg/errors
+type stackTracer interface {
+	StackTrace() errors.StackTrace
+}
+
+// unwrapErrors returns errors wrapped by err, by Unwrap() error or Unwrap() []error
+func unwrapErrors(err error) []error {
+	switch x := err.(type) {
+	case interface{ Unwrap() []error }:
+		return x.Unwrap()
+	case interface{ Unwrap() error }:
+		if e := x.Unwrap(); e != nil {
+			return []error{e}
+		}
+	}
+	return nil
+}
+
+// walkErrors calls fn for err and all errors wrapped by it in depth-first order
+func walkErrors(err error, fn func(err error)) {
+	if err == nil {
+		return
+	}
+	fn(err)
+	for _, e := range unwrapErrors(err) {
+		walkErrors(e, fn)
+	}
+}
+
+// errorStack returns the stack carried by the innermost error of the chain, which is where the error was created
+func errorStack(err error) Stack {
+	var tracer stackTracer
+	walkErrors(err, func(err error) {
+		if t, ok := err.(stackTracer); ok {
+			tracer = t
+		}
+	})
+	if tracer == nil {
+		return nil
+	}
+
+	st := tracer.StackTrace()
+	stack := make(Stack, 0, len(st))
+	for _, f := range st {
+		pc := uintptr(f) - 1
+		fn := runtime.FuncForPC(pc)
+		if fn == nil {
+			continue
+		}
+		file, line := fn.FileLine(pc)
+		stack = append(stack, StackFrame{Function: fn.Name(), File: file, Line: line})
+	}
+	return stack
+}
+
+// callerStack returns the stack of the log call, frames of skipPkgs on top of it are skipped
+func callerStack(skipPkgs []string) Stack {
+	pcs := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	stack := make(Stack, 0, n)
+	for {
+		frame, more := frames.Next()
+		if len(stack) > 0 || !funcInPackages(frame.Function, skipPkgs) {
+			stack = append(stack, StackFrame{Function: frame.Function, File: frame.File, Line: frame.Line})
+		}
+		if !more {
+			break
+		}
+	}
+	return stack
+}
+
+// errorExpander adds fields of the error set by WithError, and the stack of entries at stackLevel or more severe
+type errorExpander struct {
+	expand     bool
+	stack      bool
+	stackLevel logrus.Level
+}
+
+// expandEntry changes the entry in place, it runs before redactor so that error messages in fields are redacted
+func (x *errorExpander) expandEntry(e *logrus.Entry) {
+	err, isErr := e.Data[logrus.ErrorKey].(error)
+
+	if x.expand && isErr && err != nil {
+		e.Data[FieldErrorType] = fmt.Sprintf("%T", err)
+
+		var chain ErrorChain
+		for _, wrapped := range unwrapErrors(err) {
+			walkErrors(wrapped, func(err error) {
+				// pkg/errors.Wrap wraps twice with the same message
+				if msg := err.Error(); len(chain) == 0 || chain[len(chain)-1] != msg {
+					chain = append(chain, msg)
+				}
+			})
+		}
+		if len(chain) > 0 {
+			e.Data[FieldErrorChain] = chain
+		}
+
+		if stack := errorStack(err); len(stack) > 0 {
+			e.Data[FieldErrorStack] = stack
+		}
+	}
+
+	if x.stack && e.Level <= x.stackLevel {
+		_, hasStack := e.Data[FieldStack]
+		_, hasErrorStack := e.Data[FieldErrorStack]
+		if !hasStack && !hasErrorStack {
+			e.Data[FieldStack] = callerStack(gEntrySkipPkgs)
+		}
+	}
+}
+
+// getErrorExpander returns nil if errors are not expanded and stacks are not captured
+func getErrorExpander() (*errorExpander, error) {
+	x := &errorExpander{expand: v.GetBool(keyErrorsExpand)}
+
+	if l := v.GetString(keyErrorsStackLevel); l != "" {
+		level, err := logrus.ParseLevel(l)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", keyErrorsStackLevel, err)
+		}
+		x.stack, x.stackLevel = true, level
+	}
+
+	if !x.expand && !x.stack {
+		return nil, nil
+	}
+	return x, nil
+}
+
+var _InitErrorFields = func() interface{} {
+	registerConfigKey(keyErrorsExpand, nil)
+	registerConfigKey(keyErrorsStackLevel, checkLevel)
+	return nil
+}()
@@ -3,35 +3,29 @@ package qlog
 import (
 	"bytes"
 	"fmt"
+	"io"
+	"os"
 	"reflect"
 	"runtime"
+	"slices"
+	"strconv"
+	"sync"
+	"unicode"
+	"unicode/utf8"
 
+	"github.com/mattn/go-isatty"
 	"github.com/sirupsen/logrus"
 )
 
-//copy from old logrus code
-func prefixFieldClashes(data logrus.Fields, hasCaller bool) {
-	if t, ok := data["time"]; ok {
-		data["fields.time"] = t
-	}
-
-	if m, ok := data["msg"]; ok {
-		data["fields.msg"] = m
-	}
-
-	if l, ok := data["level"]; ok {
-		data["fields.level"] = l
-	}
-
-	if hasCaller {
-		if fc, ok := data["func"]; ok {
-			data["fields.func"] = fc
-		}
-
-		if f, ok := data["file"]; ok {
-			data["fields.file"] = f
-		}
+// fieldClashes checks if a field clashes with keys of old logrus text formatter, it is written as fields.key
+func fieldClashes(key string, hasCaller bool) bool {
+	switch key {
+	case "time", "msg", "level":
+		return true
+	case "func", "file":
+		return hasCaller
 	}
+	return false
 }
 
 // ShortLevel is a simple char to indicate log level
@@ -60,6 +54,20 @@ func (level ShortLevel) String() string {
 	return "X"
 }
 
+var (
+	// cached level strings of ClassicFormatter, e.g. [I]
+	gClassicLevels [logrus.TraceLevel + 1]string
+	// cached ansi colors of levels
+	gLevelColors [logrus.TraceLevel + 1]string
+)
+
+func classicLevel(level logrus.Level) string {
+	if level <= logrus.TraceLevel {
+		return gClassicLevels[level]
+	}
+	return "[X]"
+}
+
 // ClassicFormatter formats logs into parsable json
 type ClassicFormatter struct {
 	// TimestampFormat sets the format used for marshaling timestamps.
@@ -73,87 +81,356 @@ type ClassicFormatter struct {
 	// activated. If any of the returned value is the empty string the
 	// corresponding key will be removed from fields.
 	CallerPrettyfier func(*runtime.Frame) (function string, file string)
+
+	// FieldOrder lists fields written first in the order, other fields are sorted by key
+	FieldOrder []string
+
+	// PrefixFields lists fields written before the message in the order, e.g. requestId
+	PrefixFields []string
+
+	// DisableSorting writes fields not in FieldOrder in random order
+	DisableSorting bool
+
+	// DisableQuote disables quoting of values, control chars are still escaped
+	DisableQuote bool
+
+	// ForceColors colors levels and field keys and dims timestamps and callers even if the writer is not a terminal
+	ForceColors bool
+
+	// DisableColors disables colors even if the writer is a terminal
+	DisableColors bool
+
+	terminal bool // the writer of the hook is a terminal
 }
 
-// Format renders a single log entry
-func (f *ClassicFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+const (
+	colorRed    = 31
+	colorYellow = 33
+	colorBlue   = 36
+	colorGray   = 37
+
+	ansiDim   = "\x1b[2m"
+	ansiReset = "\x1b[0m"
+)
+
+func levelColorCode(level logrus.Level) int {
+	switch level {
+	case logrus.TraceLevel, logrus.DebugLevel:
+		return colorGray
+	case logrus.WarnLevel:
+		return colorYellow
+	case logrus.ErrorLevel, logrus.FatalLevel, logrus.PanicLevel:
+		return colorRed
+	}
+	return colorBlue
+}
+
+// isTerminal checks if w is a terminal, e.g. os.Stdout of a console
+func isTerminal(w io.Writer) bool {
+	if f, ok := w.(*os.File); ok {
+		return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+	}
+	return false
+}
+
+// forWriter returns a copy of the formatter detecting if w is a terminal, hooks sharing a formatter get their copies
+func (f *ClassicFormatter) forWriter(w io.Writer) logrus.Formatter {
+	c := *f
+	c.terminal = isTerminal(w)
+	return &c
+}
+
+func levelColor(level logrus.Level) string {
+	if level <= logrus.TraceLevel {
+		return gLevelColors[level]
+	}
+	return gLevelColors[logrus.InfoLevel]
+}
+
+func (f *ClassicFormatter) colored() bool {
+	return (f.ForceColors || f.terminal) && !f.DisableColors
+}
 
-	prefixFieldClashes(entry.Data, entry.HasCaller())
+// callerKey is the key of cached caller strings
+type callerKey struct {
+	file string
+	line int
+}
+
+var (
+	gCallerMu    sync.RWMutex
+	gCallerFiles = make(map[callerKey]string) // cached file:line of callers, callers are limited by code
+)
+
+// callerFile returns file:line of a caller
+func callerFile(caller *runtime.Frame) string {
+	key := callerKey{caller.File, caller.Line}
 
-	var b *bytes.Buffer
+	gCallerMu.RLock()
+	s, ok := gCallerFiles[key]
+	gCallerMu.RUnlock()
+	if ok {
+		return s
+	}
+
+	s = caller.File + ":" + strconv.Itoa(caller.Line)
+	gCallerMu.Lock()
+	gCallerFiles[key] = s
+	gCallerMu.Unlock()
+	return s
+}
 
-	if entry.Buffer != nil {
-		b = entry.Buffer
-	} else {
+// classicKeys are pooled key slices of Format
+type classicKeys struct {
+	keys []string
+}
+
+var gClassicKeysPool = sync.Pool{New: func() interface{} { return &classicKeys{} }}
+
+// Format renders a single log entry, it doesn't allocate for fields of strings, numbers and bools if entry.Buffer is set
+func (f *ClassicFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	b := entry.Buffer
+	if b == nil {
 		b = &bytes.Buffer{}
 	}
 
-	timestampFormat := f.TimestampFormat
-	if timestampFormat == "" {
-		timestampFormat = longTimeStamp
+	hasCaller := entry.HasCaller()
+
+	// escape sequences of colors, empty if colors are disabled
+	var dim, color, reset string
+	if f.colored() {
+		dim, color, reset = ansiDim, levelColor(entry.Level), ansiReset
 	}
 
 	if !f.DisableTimestamp {
-		b.WriteString(entry.Time.Format(timestampFormat))
+		timestampFormat := f.TimestampFormat
+		if timestampFormat == "" {
+			timestampFormat = longTimeStamp
+		}
+
+		b.WriteString(dim)
+		b.Write(entry.Time.AppendFormat(b.AvailableBuffer(), timestampFormat))
+		b.WriteString(reset)
 	}
 
 	//reportcaller is enabled
-	if entry.HasCaller() {
-		funcVal := entry.Caller.Function
-		fileVal := fmt.Sprintf("%s:%d", entry.Caller.File, entry.Caller.Line)
+	if hasCaller {
+		var funcVal, fileVal string
 		if f.CallerPrettyfier != nil {
 			funcVal, fileVal = f.CallerPrettyfier(entry.Caller)
+		} else {
+			funcVal, fileVal = entry.Caller.Function, callerFile(entry.Caller)
 		}
 
 		if len(fileVal) > 0 {
-			f.appendValueOnly(b, fileVal)
+			appendCaller(b, fileVal, dim, reset)
 		}
 
 		if len(funcVal) > 0 {
-			f.appendValueOnly(b, funcVal)
+			appendCaller(b, funcVal, dim, reset)
 		}
 	}
 
-	f.appendValueOnly(b, fmt.Sprintf("[%s]", ShortLevel(entry.Level).String()))
+	if b.Len() > 0 {
+		b.WriteByte(' ')
+	}
+	b.WriteString(color)
+	b.WriteString(classicLevel(entry.Level))
+	b.WriteString(reset)
+
+	for _, k := range f.PrefixFields {
+		if v, ok := entry.Data[k]; ok {
+			f.appendKeyValue(b, k, v, hasCaller, color, reset)
+		}
+	}
 
 	if len(entry.Message) > 0 {
-		f.appendValueOnly(b, entry.Message)
+		b.WriteByte(' ')
+		escapeMessage(b, entry.Message)
 	}
 
-	for k, v := range entry.Data {
-		f.appendKeyValue(b, k, v)
+	keys := gClassicKeysPool.Get().(*classicKeys)
+	f.fieldKeys(entry.Data, keys)
+
+	for _, k := range keys.keys {
+		f.appendKeyValue(b, k, entry.Data[k], hasCaller, color, reset)
 	}
 
+	gClassicKeysPool.Put(keys)
+
 	b.WriteByte('\n')
 	return b.Bytes(), nil
 }
 
-func (f *ClassicFormatter) appendKeyValue(b *bytes.Buffer, key string, value interface{}) {
-	if b.Len() > 0 {
-		b.WriteByte(' ')
+func containsKey(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
 	}
-	b.WriteString(key)
+	return false
+}
+
+// fieldKeys sets keys of fields written after the message in order
+func (f *ClassicFormatter) fieldKeys(data logrus.Fields, keys *classicKeys) {
+	keys.keys = keys.keys[:0]
+
+	for _, k := range f.FieldOrder {
+		if _, ok := data[k]; ok && !containsKey(f.PrefixFields, k) {
+			keys.keys = append(keys.keys, k)
+		}
+	}
+	ordered := len(keys.keys)
+
+	for k := range data {
+		if !containsKey(f.FieldOrder, k) && !containsKey(f.PrefixFields, k) {
+			keys.keys = append(keys.keys, k)
+		}
+	}
+
+	if !f.DisableSorting {
+		slices.Sort(keys.keys[ordered:])
+	}
+}
+
+// appendKeyValue writes key=value, key is wrapped in color and reset, and quoted as values are
+func (f *ClassicFormatter) appendKeyValue(b *bytes.Buffer, key string, value interface{}, hasCaller bool, color string, reset string) {
+	b.WriteByte(' ')
+	b.WriteString(color)
+	if fieldClashes(key, hasCaller) {
+		key = "fields." + key
+	}
+	f.appendString(b, key)
+	b.WriteString(reset)
 	b.WriteByte('=')
 	f.appendValue(b, value)
 }
 
-func (f *ClassicFormatter) appendValueOnly(b *bytes.Buffer, value interface{}) {
+// appendCaller writes a caller value wrapped in color and reset, it is not quoted so that it can be parsed by
+// the grok pattern of filebeat, only control chars are escaped
+func appendCaller(b *bytes.Buffer, s string, color string, reset string) {
 	if b.Len() > 0 {
 		b.WriteByte(' ')
 	}
-	f.appendValue(b, value)
+	b.WriteString(color)
+	escapeControl(b, s)
+	b.WriteString(reset)
 }
 
+// appendValue writes strings, numbers and bools by strconv, other values by fmt.Sprint
 func (f *ClassicFormatter) appendValue(b *bytes.Buffer, value interface{}) {
-	stringVal, ok := value.(string)
-	if !ok {
-		stringVal = fmt.Sprint(value)
+	if s, ok := value.(string); ok {
+		f.appendString(b, s)
+	} else if !appendNumber(b, value) {
+		f.appendString(b, fmt.Sprint(value))
 	}
+}
 
-	b.WriteString(fmt.Sprintf("%s", stringVal))
+// appendNumber writes numbers and bools by strconv without allocations, it returns false for other values
+func appendNumber(b *bytes.Buffer, value interface{}) bool {
+	switch val := value.(type) {
+	case int:
+		b.Write(strconv.AppendInt(b.AvailableBuffer(), int64(val), 10))
+	case int64:
+		b.Write(strconv.AppendInt(b.AvailableBuffer(), val, 10))
+	case int32:
+		b.Write(strconv.AppendInt(b.AvailableBuffer(), int64(val), 10))
+	case int16:
+		b.Write(strconv.AppendInt(b.AvailableBuffer(), int64(val), 10))
+	case int8:
+		b.Write(strconv.AppendInt(b.AvailableBuffer(), int64(val), 10))
+	case uint:
+		b.Write(strconv.AppendUint(b.AvailableBuffer(), uint64(val), 10))
+	case uint64:
+		b.Write(strconv.AppendUint(b.AvailableBuffer(), val, 10))
+	case uint32:
+		b.Write(strconv.AppendUint(b.AvailableBuffer(), uint64(val), 10))
+	case uint16:
+		b.Write(strconv.AppendUint(b.AvailableBuffer(), uint64(val), 10))
+	case uint8:
+		b.Write(strconv.AppendUint(b.AvailableBuffer(), uint64(val), 10))
+	case float64:
+		b.Write(strconv.AppendFloat(b.AvailableBuffer(), val, 'g', -1, 64))
+	case float32:
+		b.Write(strconv.AppendFloat(b.AvailableBuffer(), float64(val), 'g', -1, 32))
+	case bool:
+		b.Write(strconv.AppendBool(b.AvailableBuffer(), val))
+	default:
+		return false
+	}
+	return true
+}
+
+func (f *ClassicFormatter) appendString(b *bytes.Buffer, s string) {
+	if !f.DisableQuote && needsQuoting(s) {
+		b.Write(strconv.AppendQuote(b.AvailableBuffer(), s))
+		return
+	}
+	escapeControl(b, s)
 }
 
+// needsQuoting checks if a value can't be parsed as a key=value pair without quotes
+func needsQuoting(s string) bool {
+	if len(s) == 0 {
+		return true
+	}
+	for _, r := range s {
+		if r <= ' ' || r == '=' || r == '"' || r == 0x7f || r == utf8.RuneError || !unicode.IsPrint(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// escapeControl writes s with control chars escaped as in go strings, so that an entry is always one line
+func escapeControl(b *bytes.Buffer, s string) {
+	last := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= ' ' && c != 0x7f {
+			continue
+		}
+
+		b.WriteString(s[last:i])
+		switch c {
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			b.WriteString(`\x`)
+			b.WriteByte(hexDigits[c>>4])
+			b.WriteByte(hexDigits[c&0xf])
+		}
+		last = i + 1
+	}
+	b.WriteString(s[last:])
+}
+
+// escapeMessage writes s with control chars and backslashes escaped, so that escaped chars in the message
+// can't be confused with escapes
+func escapeMessage(b *bytes.Buffer, s string) {
+	last := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' {
+			escapeControl(b, s[last:i])
+			b.WriteString(`\\`)
+			last = i + 1
+		}
+	}
+	escapeControl(b, s[last:])
+}
+
+const hexDigits = "0123456789abcdef"
+
 var _InitClassicFormatter = func() interface{} {
+	for _, level := range logrus.AllLevels {
+		gClassicLevels[level] = "[" + ShortLevel(level).String() + "]"
+		gLevelColors[level] = "\x1b[" + strconv.Itoa(levelColorCode(level)) + "m"
+	}
+
 	registeFormatter("classic", reflect.TypeOf(ClassicFormatter{}))
 	return nil
 }()
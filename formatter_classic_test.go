@@ -0,0 +1,159 @@
+package qlog
+
+import (
+	"bytes"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// classicTestEntry returns an entry with a caller and fields of mixed types
+func classicTestEntry() *logrus.Entry {
+	l := logrus.New()
+	l.SetReportCaller(true)
+
+	pc, file, line, _ := runtime.Caller(0)
+	e := logrus.NewEntry(l).WithFields(logrus.Fields{
+		"method":  "GET",
+		"path":    "/api/v1/users",
+		"status":  200,
+		"latency": 12,
+		"ratio":   0.5,
+		"ok":      true,
+		"error":   errors.New("some error"),
+	})
+	e.Time = time.Now()
+	e.Level = logrus.InfoLevel
+	e.Message = "request served"
+	e.Caller = &runtime.Frame{PC: pc, File: file, Line: line, Function: runtime.FuncForPC(pc).Name()}
+	return e
+}
+
+// BenchmarkClassicFormatter formats classicTestEntry, before keys were pooled and values written by strconv:
+//
+//	BenchmarkClassicFormatter	17 allocs/op	736 B/op
+//
+// now the only allocation is fmt.Sprint of the error field:
+//
+//	BenchmarkClassicFormatter	1 allocs/op	16 B/op
+func BenchmarkClassicFormatter(b *testing.B) {
+	f := &ClassicFormatter{}
+	e := classicTestEntry()
+	e.Buffer = &bytes.Buffer{}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		e.Buffer.Reset()
+		if _, err := f.Format(e); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestClassicFormatterAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("allocations are not stable with the race detector, which drops pooled objects randomly")
+	}
+
+	f := &ClassicFormatter{}
+	e := classicTestEntry()
+	e.Buffer = &bytes.Buffer{}
+
+	// the only allocation is fmt.Sprint of the error field
+	allocs := testing.AllocsPerRun(100, func() {
+		e.Buffer.Reset()
+		f.Format(e)
+	})
+	if allocs > 1 {
+		t.Errorf("ClassicFormatter.Format allocs %v per entry, want <= 1", allocs)
+	}
+}
+
+func TestClassicFormatterOutput(t *testing.T) {
+	tests := []struct {
+		name   string
+		f      ClassicFormatter
+		msg    string
+		fields logrus.Fields
+		want   string
+	}{
+		{
+			name:   "sorted fields",
+			msg:    "served",
+			fields: logrus.Fields{"status": 200, "method": "GET", "path": "/a", "ok": true, "ratio": 0.5},
+			want:   "[I] served method=GET ok=true path=/a ratio=0.5 status=200",
+		},
+		{
+			name:   "field order",
+			f:      ClassicFormatter{FieldOrder: []string{"status", "method", "missing"}},
+			msg:    "served",
+			fields: logrus.Fields{"status": 200, "method": "GET", "path": "/a", "ok": true},
+			want:   "[I] served status=200 method=GET ok=true path=/a",
+		},
+		{
+			name:   "prefix fields",
+			f:      ClassicFormatter{PrefixFields: []string{"requestId", "missing"}, FieldOrder: []string{"requestId", "status"}},
+			msg:    "served",
+			fields: logrus.Fields{"requestId": "r1", "status": 200, "method": "GET"},
+			want:   "[I] requestId=r1 served status=200 method=GET",
+		},
+		{
+			name:   "clashing keys",
+			msg:    "m",
+			fields: logrus.Fields{"msg": "x", "level": "y", "time": "z", "file": "f"},
+			want:   "[I] m file=f fields.level=y fields.msg=x fields.time=z",
+		},
+		{
+			name:   "quoted values",
+			msg:    "m",
+			fields: logrus.Fields{"a": "with space", "b": "k=v", "c": `say "hi"`, "d": "", "e": "line\nbreak", "f": `back\slash`},
+			want:   `[I] m a="with space" b="k=v" c="say \"hi\"" d="" e="line\nbreak" f=back\slash`,
+		},
+		{
+			name:   "quoted keys",
+			msg:    "m",
+			fields: logrus.Fields{"k=v": `q"`, "a b": 1, `x"y`: 2},
+			want:   `[I] m "a b"=1 "k=v"="q\"" "x\"y"=2`,
+		},
+		{
+			name:   "disable quote",
+			f:      ClassicFormatter{DisableQuote: true},
+			msg:    "m",
+			fields: logrus.Fields{"a b": "with space\n"},
+			want:   `[I] m a b=with space\n`,
+		},
+		{
+			name: "escaped message",
+			msg:  "line1\nline2\ttab \\n \x01 \"q\"",
+			want: `[I] line1\nline2\ttab \\n \x01 "q"`,
+		},
+		{
+			name:   "empty message",
+			fields: logrus.Fields{"a": 1},
+			want:   "[I] a=1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := tt.f
+			f.DisableTimestamp = true
+
+			e := logrus.NewEntry(logrus.New()).WithFields(tt.fields)
+			e.Level = logrus.InfoLevel
+			e.Message = tt.msg
+
+			b, err := f.Format(e)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(b); got != tt.want+"\n" {
+				t.Errorf("got  %q\nwant %q", got, tt.want+"\n")
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package qlog
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+)
+
+// LogfmtFormatter formats logs into logfmt, e.g. ts=2006-01-02T15:04:05.999Z level=info caller=main.go:12 msg=hello key=value,
+// which can be parsed by `| logfmt` of Loki
+type LogfmtFormatter struct {
+	// TimestampFormat sets the format of ts, default is time.RFC3339Nano
+	TimestampFormat string
+
+	// DisableTimestamp disables the ts key
+	DisableTimestamp bool
+
+	// CallerPrettyfier modifies the caller and func values when ReportCaller is activated, an empty value is omitted.
+	// The caller is file:line and func is omitted by default.
+	CallerPrettyfier func(*runtime.Frame) (function string, file string)
+
+	// key names, defaults are ts, level, caller, func and msg
+	TimeKey    string
+	LevelKey   string
+	CallerKey  string
+	FuncKey    string
+	MessageKey string
+
+	// FieldOrder lists fields written first in the order, other fields are sorted by key
+	FieldOrder []string
+
+	// DisableSorting writes fields not in FieldOrder in random order
+	DisableSorting bool
+}
+
+// cached level names, logrus.Level.String allocates
+var gLevelNames [logrus.TraceLevel + 1]string
+
+func keyOrDefault(key string, defaultKey string) string {
+	if len(key) > 0 {
+		return key
+	}
+	return defaultKey
+}
+
+// Format renders a single log entry
+func (f *LogfmtFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	b := entry.Buffer
+	if b == nil {
+		b = &bytes.Buffer{}
+	}
+
+	timeKey := keyOrDefault(f.TimeKey, "ts")
+	levelKey := keyOrDefault(f.LevelKey, "level")
+	callerKey := keyOrDefault(f.CallerKey, "caller")
+	funcKey := keyOrDefault(f.FuncKey, "func")
+	msgKey := keyOrDefault(f.MessageKey, "msg")
+
+	if !f.DisableTimestamp {
+		timestampFormat := f.TimestampFormat
+		if timestampFormat == "" {
+			timestampFormat = time.RFC3339Nano
+		}
+
+		appendLogfmtKey(b, timeKey)
+		start := b.Len()
+		b.Write(entry.Time.AppendFormat(b.AvailableBuffer(), timestampFormat))
+		if ts := b.Bytes()[start:]; bytes.IndexFunc(ts, logfmtQuoted) >= 0 {
+			s := string(ts)
+			b.Truncate(start)
+			appendLogfmtString(b, s)
+		}
+	}
+
+	appendLogfmtKey(b, levelKey)
+	if entry.Level <= logrus.TraceLevel {
+		b.WriteString(gLevelNames[entry.Level])
+	} else {
+		b.WriteString(entry.Level.String())
+	}
+
+	if entry.HasCaller() {
+		var funcVal, fileVal string
+		if f.CallerPrettyfier != nil {
+			funcVal, fileVal = f.CallerPrettyfier(entry.Caller)
+		} else {
+			fileVal = callerFile(entry.Caller)
+		}
+
+		if len(fileVal) > 0 {
+			appendLogfmtKey(b, callerKey)
+			appendLogfmtString(b, fileVal)
+		}
+		if len(funcVal) > 0 {
+			appendLogfmtKey(b, funcKey)
+			appendLogfmtString(b, funcVal)
+		}
+	}
+
+	appendLogfmtKey(b, msgKey)
+	appendLogfmtString(b, entry.Message)
+
+	keys := gClassicKeysPool.Get().(*classicKeys)
+	f.fieldKeys(entry.Data, keys)
+
+	for _, k := range keys.keys {
+		// fields clashing with keys of the formatter are written as fields.key
+		if k == timeKey || k == levelKey || k == msgKey || (entry.HasCaller() && (k == callerKey || k == funcKey)) {
+			appendLogfmtKey(b, "fields."+k)
+		} else {
+			appendLogfmtKey(b, k)
+		}
+
+		switch v := entry.Data[k].(type) {
+		case string:
+			appendLogfmtString(b, v)
+		default:
+			if !appendNumber(b, v) {
+				appendLogfmtString(b, fmt.Sprint(v))
+			}
+		}
+	}
+
+	gClassicKeysPool.Put(keys)
+
+	b.WriteByte('\n')
+	return b.Bytes(), nil
+}
+
+// fieldKeys sets keys of fields in order
+func (f *LogfmtFormatter) fieldKeys(data logrus.Fields, keys *classicKeys) {
+	keys.keys = keys.keys[:0]
+
+	for _, k := range f.FieldOrder {
+		if _, ok := data[k]; ok {
+			keys.keys = append(keys.keys, k)
+		}
+	}
+	ordered := len(keys.keys)
+
+	for k := range data {
+		if !slices.Contains(f.FieldOrder, k) {
+			keys.keys = append(keys.keys, k)
+		}
+	}
+
+	if !f.DisableSorting {
+		slices.Sort(keys.keys[ordered:])
+	}
+}
+
+// appendLogfmtKey writes a separator and key=, chars not allowed in logfmt keys (spaces, '=', '"' and control chars)
+// are replaced by '_'
+func appendLogfmtKey(b *bytes.Buffer, key string) {
+	if b.Len() > 0 {
+		b.WriteByte(' ')
+	}
+	if len(key) == 0 {
+		key = "_"
+	}
+
+	for _, r := range key {
+		if logfmtQuoted(r) {
+			b.WriteByte('_')
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('=')
+}
+
+// appendLogfmtString writes a value, it is quoted if it contains spaces, '=', '"' or control chars,
+// and escaped as a json string
+func appendLogfmtString(b *bytes.Buffer, s string) {
+	if !logfmtNeedsQuoting(s) {
+		b.WriteString(s)
+		return
+	}
+
+	b.WriteByte('"')
+	last := 0
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c >= utf8.RuneSelf {
+			r, size := utf8.DecodeRuneInString(s[i:])
+			if r == utf8.RuneError && size == 1 {
+				b.WriteString(s[last:i])
+				b.WriteString(`\ufffd`)
+				last = i + size
+			}
+			i += size
+			continue
+		}
+
+		if c >= ' ' && c != '"' && c != '\\' && c != 0x7f {
+			i++
+			continue
+		}
+
+		b.WriteString(s[last:i])
+		switch c {
+		case '"', '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			b.WriteString(`\u00`)
+			b.WriteByte(hexDigits[c>>4])
+			b.WriteByte(hexDigits[c&0xf])
+		}
+		i++
+		last = i
+	}
+	b.WriteString(s[last:])
+	b.WriteByte('"')
+}
+
+// logfmtQuoted checks if a rune can't be written in a value without quotes
+func logfmtQuoted(r rune) bool {
+	return r <= ' ' || r == '=' || r == '"' || r == 0x7f || r == utf8.RuneError
+}
+
+func logfmtNeedsQuoting(s string) bool {
+	return strings.IndexFunc(s, logfmtQuoted) >= 0
+}
+
+var _InitLogfmtFormatter = func() interface{} {
+	for _, level := range logrus.AllLevels {
+		gLevelNames[level] = level.String()
+	}
+
+	registeFormatter("logfmt", reflect.TypeOf(LogfmtFormatter{}))
+	return nil
+}()
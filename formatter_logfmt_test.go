@@ -0,0 +1,99 @@
+package qlog
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestLogfmtFormatterOutput(t *testing.T) {
+	ts := time.Date(2024, 5, 6, 7, 8, 9, 120000000, time.UTC)
+
+	tests := []struct {
+		name   string
+		f      LogfmtFormatter
+		msg    string
+		fields logrus.Fields
+		caller bool
+		want   string
+	}{
+		{
+			name:   "default keys",
+			msg:    "hello",
+			fields: logrus.Fields{"b": 2, "a": "x", "ok": true, "ratio": 0.5},
+			want:   "ts=2024-05-06T07:08:09.12Z level=info msg=hello a=x b=2 ok=true ratio=0.5",
+		},
+		{
+			name: "configured keys",
+			f: LogfmtFormatter{TimeKey: "time", LevelKey: "lvl", CallerKey: "src", FuncKey: "fn", MessageKey: "message",
+				CallerPrettyfier: func(*runtime.Frame) (string, string) { return "main.run", "main.go:12" }},
+			msg:    "hello",
+			caller: true,
+			want:   "time=2024-05-06T07:08:09.12Z lvl=info src=main.go:12 fn=main.run message=hello",
+		},
+		{
+			name:   "clashing keys",
+			f:      LogfmtFormatter{MessageKey: "message"},
+			msg:    "hello",
+			fields: logrus.Fields{"message": "m", "msg": "x", "level": "l", "ts": "t"},
+			want:   "ts=2024-05-06T07:08:09.12Z level=info message=hello fields.level=l fields.message=m msg=x fields.ts=t",
+		},
+		{
+			name:   "field order",
+			f:      LogfmtFormatter{DisableTimestamp: true, FieldOrder: []string{"status", "method", "missing"}},
+			msg:    "served",
+			fields: logrus.Fields{"path": "/a", "method": "GET", "status": 200},
+			want:   "level=info msg=served status=200 method=GET path=/a",
+		},
+		{
+			name: "timestamp format with spaces",
+			f:    LogfmtFormatter{TimestampFormat: time.DateTime},
+			msg:  "hello",
+			want: `ts="2024-05-06 07:08:09" level=info msg=hello`,
+		},
+		{
+			name: "quoted values",
+			f:    LogfmtFormatter{DisableTimestamp: true},
+			msg:  "hello world",
+			fields: logrus.Fields{"space": "a b", "eq": "k=v", "quote": `say "hi"`, "nl": "line1\nline2",
+				"bs": `C:\dir`, "bsq": `C:\my dir`, "ctl": "a\x01\tb", "empty": "", "bad": "a\xffb"},
+			want: `level=info msg="hello world" bad="a\ufffdb" bs=C:\dir bsq="C:\\my dir" ctl="a\u0001\tb" empty= eq="k=v" nl="line1\nline2" quote="say \"hi\"" space="a b"`,
+		},
+		{
+			name:   "invalid keys",
+			f:      LogfmtFormatter{DisableTimestamp: true},
+			msg:    "m",
+			fields: logrus.Fields{"a b": 1, "k=v": 2, `x"y`: 3, "": 4},
+			want:   `level=info msg=m _=4 a_b=1 k_v=2 x_y=3`,
+		},
+		{
+			name: "empty message",
+			f:    LogfmtFormatter{DisableTimestamp: true},
+			want: "level=info msg=",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := logrus.New()
+			l.SetReportCaller(tt.caller)
+
+			e := logrus.NewEntry(l).WithFields(tt.fields).WithTime(ts)
+			e.Level = logrus.InfoLevel
+			e.Message = tt.msg
+			if tt.caller {
+				e.Caller = &runtime.Frame{}
+			}
+
+			b, err := tt.f.Format(e)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(b); got != tt.want+"\n" {
+				t.Errorf("got  %q\nwant %q", got, tt.want+"\n")
+			}
+		})
+	}
+}
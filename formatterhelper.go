@@ -13,15 +13,17 @@ import (
 const (
 	longTimeStamp   = "2006/01/02 15:04:05.000000Z07:00"
 	shortTimeStamp  = "06/01/02 15:04:05.000"
-	keyPrettyCaller = "prettycaller" // omitfunc, truncated
+	keyPrettyCaller = "prettycaller" // omitfunc, truncated, module, shortfunc, registered names or a template
 )
 
 var (
 	gRegisteredFormatters = make(map[string]reflect.Type)
 
-	gPrettyCallFuncMap = map[string]func(*runtime.Frame) (function string, file string){
+	gPrettyCallFuncMap = map[string]CallerPrettyfier{
 		"omitfunc":  prettyCallerOmitFunc,
 		"truncated": prettyCallerTruncated,
+		"module":    prettyCallerModule,
+		"shortfunc": prettyCallerShortFunc,
 	}
 )
 
@@ -79,7 +81,7 @@ func newFormatter(name string, key string) (logrus.Formatter, error) {
 	var ok bool
 
 	if typ, ok = gRegisteredFormatters[name]; !ok {
-		return nil, fmt.Errorf("[qlog] formatter name(%s) not registered", name)
+		return nil, fmt.Errorf("formatter name(%s) not registered", name)
 	}
 
 	f := reflect.New(typ)
@@ -92,15 +94,16 @@ func newFormatter(name string, key string) (logrus.Formatter, error) {
 	prettyCaller := v.GetString(key + "." + keyPrettyCaller)
 
 	if len(prettyCaller) > 0 {
-		if prettyFunc, ok := gPrettyCallFuncMap[prettyCaller]; ok {
-			prettyFuncField := f.Elem().FieldByName("CallerPrettyfier")
-			if prettyFuncField.IsValid() {
-				prettyFuncField.Set(reflect.ValueOf(prettyFunc))
-			} else {
-				return nil, fmt.Errorf("[qlog] formatter name(%s) doesn't support truncate caller", name)
-			}
+		prettyFunc, err := lookupCallerPrettyfier(prettyCaller)
+		if err != nil {
+			return nil, fmt.Errorf("formatter name(%s) init fail:%s", name, err)
+		}
+
+		prettyFuncField := f.Elem().FieldByName("CallerPrettyfier")
+		if prettyFuncField.IsValid() {
+			prettyFuncField.Set(reflect.ValueOf(prettyFunc))
 		} else {
-			return nil, fmt.Errorf("[qlog] formatter name(%s) init with unsupported pretty func:%s", name, prettyCaller)
+			return nil, fmt.Errorf("formatter name(%s) doesn't support truncate caller", name)
 		}
 	}
 
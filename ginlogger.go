@@ -1,17 +1,14 @@
 package qlog
 
 import (
-	"bytes"
-	"fmt"
 	"io"
-	"strings"
+	"net/http"
 	"time"
 
 	"math"
-	"os"
+	"regexp"
 
 	"github.com/gin-gonic/gin"
-	"github.com/gin-gonic/gin/binding"
 	"github.com/sirupsen/logrus"
 )
 
@@ -23,53 +20,146 @@ func getGinLogger(logger ...*logrus.Entry) *logrus.Entry {
 	return logger[0]
 }
 
+// CaptureGinLog redirects gin.DefaultWriter at level and gin.DefaultErrorWriter at error level to qlog,
+// it must be called before gin.New() or gin.Default()
+func CaptureGinLog(level logrus.Level) {
+	gin.DefaultWriter = NewWriter("gin", level)
+	gin.DefaultErrorWriter = NewWriter("gin", logrus.ErrorLevel)
+}
+
+// GinLevelRoutes registers LevelHandler to GET and PUT relativePath
+func GinLevelRoutes(r gin.IRoutes, relativePath string) {
+	h := gin.WrapH(LevelHandler())
+	r.GET(relativePath, h)
+	r.PUT(relativePath, h)
+}
+
+// GinRequestID reads or generates the request id (X-Request-ID) and parses W3C traceparent of a request,
+// stores a request-scoped entry with requestId, traceId, spanId and traceFlags fields in gin.Context and request context,
+// and echoes the request id in response. Handlers get the entry by FromContext(c) or FromContext(c.Request.Context()).
+func GinRequestID(logger ...*logrus.Entry) gin.HandlerFunc {
+	var log *logrus.Entry
+	if len(logger) == 0 {
+		log = logrus.NewEntry(qLogger)
+	} else {
+		log = logger[0]
+	}
+
+	return func(c *gin.Context) {
+		rid := requestID(c.Request.Header)
+		entry := log.WithFields(requestFields(c.Request.Header, rid))
+
+		c.Set(ginKeyEntry, entry)
+		c.Request = c.Request.WithContext(NewContext(c.Request.Context(), entry))
+		c.Header(HeaderRequestID, rid)
+
+		c.Next()
+	}
+}
+
+// ginRequestFields returns fields of the request-scoped entry set by GinRequestID
+func ginRequestFields(c *gin.Context) logrus.Fields {
+	if v, ok := c.Get(ginKeyEntry); ok {
+		if entry, ok := v.(*logrus.Entry); ok {
+			return entry.Data
+		}
+	}
+	return nil
+}
+
+// GinLoggerConfig is the config of GinLoggerWithConfig
+type GinLoggerConfig struct {
+	// Logger is the base logger, default is qlog default logger with field pkg=gin
+	Logger *logrus.Entry
+
+	// SkipPaths are request paths not logged, e.g. /healthz
+	SkipPaths []string
+	// SkipRegexps skip request paths matching any of them
+	SkipRegexps []*regexp.Regexp
+
+	// StatusLevel maps response status to level, default is DefaultStatusLevel
+	StatusLevel func(status int) logrus.Level
+
+	// FieldNames renames default fields, e.g. {"statusCode": "status"}, an empty name omits the field
+	FieldNames map[string]string
+
+	// Route logs the route template by c.FullPath() as field route
+	Route bool
+	// RequestHeaders are request headers logged as field reqHeaders
+	RequestHeaders []string
+	// ResponseHeaders are response headers logged as field rspHeaders
+	ResponseHeaders []string
+
+	// RouteSampling logs 1 of every N requests of a route template, requests logged at warn level or higher are never sampled
+	RouteSampling map[string]uint64
+
+	// SlowThreshold escalates the level of requests slower than it to SlowLevel, 0 disables it
+	SlowThreshold time.Duration
+	// SlowLevel is the level of slow requests, default is warn
+	SlowLevel logrus.Level
+	// SlowLevelSet uses SlowLevel even if it is 0, which is panic level, logrus panics after logging at panic level
+	SlowLevelSet bool
+
+	// Format formats requests as access log lines, "common" (or "ncsa") and "combined" are apache formats,
+	// others are apache style templates, e.g. `%h %l %u %t "%r" %>s %b %D`. Lines are written to the access log
+	// file if logger.access.enabled is set, or logged as messages. Empty Format logs structured entries.
+	Format string
+}
+
 // GinLogger is the qlog logger for GIN, copy from https://github.com/toorop/gin-logrus
 func GinLogger(logger ...*logrus.Entry) gin.HandlerFunc {
-	log := getGinLogger(logger...)
+	return GinLoggerWithConfig(GinLoggerConfig{Logger: getGinLogger(logger...)})
+}
+
+// GinLoggerWithConfig returns a GinLogger with config
+func GinLoggerWithConfig(conf GinLoggerConfig) gin.HandlerFunc {
+	if conf.Logger == nil {
+		conf.Logger = getGinLogger()
+	}
+
+	l := newAccessLogger(conf)
+	log := conf.Logger
 
 	return func(c *gin.Context) {
 		path := c.Request.URL.Path
+		if l.skipped(path) {
+			c.Next()
+			return
+		}
+
 		start := time.Now()
 		c.Next()
-		stop := time.Since(start)
-		latency := int(math.Ceil(float64(stop.Nanoseconds()) / 1000000.0))
-		statusCode := c.Writer.Status()
-		clientIP := c.ClientIP()
-		clientUserAgent := c.Request.UserAgent()
-		referer := c.Request.Referer()
-		hostname, err := os.Hostname()
-		if err != nil {
-			hostname = "unknown"
-		}
+
 		dataLength := c.Writer.Size()
 		if dataLength < 0 {
 			dataLength = 0
 		}
 
-		entry := log.WithFields(logrus.Fields{
-			"hostname":   hostname,
-			"statusCode": statusCode,
-			"latency":    latency, // time to process
-			"clientIP":   clientIP,
-			"method":     c.Request.Method,
-			"path":       path,
-			"referer":    referer,
-			"dataLength": dataLength,
-			"userAgent":  clientUserAgent,
-		})
+		user, _, _ := c.Request.BasicAuth()
+		info := &accessInfo{
+			start:      start,
+			method:     c.Request.Method,
+			path:       path,
+			query:      c.Request.URL.RawQuery,
+			requestURI: requestURI(c.Request),
+			proto:      c.Request.Proto,
+			user:       user,
+			route:      c.FullPath(),
+			status:     c.Writer.Status(),
+			latency:    time.Since(start),
+			clientIP:   c.ClientIP(),
+			userAgent:  c.Request.UserAgent(),
+			referer:    c.Request.Referer(),
+			dataLength: dataLength,
+			reqHeader:  c.Request.Header,
+			rspHeader:  c.Writer.Header(),
+		}
 
 		if len(c.Errors) > 0 {
-			entry.Error(c.Errors.ByType(gin.ErrorTypePrivate).String())
-		} else {
-			msg := fmt.Sprintf("%d \"%s %s\" (%dms)", statusCode, c.Request.Method, path, latency)
-			if statusCode > 499 {
-				entry.Error(msg)
-			} else if statusCode > 399 {
-				entry.Warn(msg)
-			} else {
-				entry.Trace(msg)
-			}
+			info.errMsg = c.Errors.ByType(gin.ErrorTypePrivate).String()
 		}
+
+		l.log(log.WithFields(ginRequestFields(c)), info)
 	}
 }
 
@@ -88,10 +178,6 @@ type ginResponseMultiWriter struct {
 	mw io.Writer
 }
 
-const (
-	trimmedMsgLength = 16 * 1024
-)
-
 func GinMultiWriter(gw gin.ResponseWriter, w io.Writer) gin.ResponseWriter {
 	mw := io.MultiWriter(gw, w)
 
@@ -102,76 +188,89 @@ func (w *ginResponseMultiWriter) Write(p []byte) (n int, err error) {
 	return w.mw.Write(p)
 }
 
+// ginCaptureWriter captures the response body, flushed responses are streamed and not captured
+type ginCaptureWriter struct {
+	gin.ResponseWriter
+	body *bodyCapture
+}
+
+func (w *ginCaptureWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	w.body.Write(p[:n])
+	return n, err
+}
+
+func (w *ginCaptureWriter) WriteString(s string) (int, error) {
+	n, err := w.ResponseWriter.WriteString(s)
+	w.body.WriteString(s[:n])
+	return n, err
+}
+
+func (w *ginCaptureWriter) Flush() {
+	w.body.stop()
+	w.ResponseWriter.Flush()
+}
+
+// GinAPILoggerConfig is the config of GinAPILoggerWithConfig
+type GinAPILoggerConfig struct {
+	// Logger is the base logger, default is qlog default logger with field pkg=gin
+	Logger *logrus.Entry
+	// Level is the level of entries, default is debug
+	Level logrus.Level
+	// LevelSet uses Level even if it is 0, which is panic level, logrus panics after logging at panic level
+	LevelSet bool
+
+	BodyCaptureConfig
+}
+
+// GinAPILogger logs requests with request and response bodies as fields reqBody and rspBody
 func GinAPILogger(logger ...*logrus.Entry) gin.HandlerFunc {
-	log := getGinLogger(logger...)
+	return GinAPILoggerWithConfig(GinAPILoggerConfig{Logger: getGinLogger(logger...)})
+}
+
+// GinAPILoggerWithConfig returns a GinAPILogger with config
+func GinAPILoggerWithConfig(conf GinAPILoggerConfig) gin.HandlerFunc {
+	if conf.Logger == nil {
+		conf.Logger = getGinLogger()
+	}
+	if conf.Level == 0 && !conf.LevelSet {
+		conf.Level = logrus.DebugLevel
+	}
+
+	log := conf.Logger
+	bodyConf := conf.BodyCaptureConfig.withDefaults()
 
 	return func(c *gin.Context) {
+		if !log.Logger.IsLevelEnabled(conf.Level) {
+			c.Next()
+			return
+		}
+
 		start := time.Now()
 
-		var bufReq, bufRsp bytes.Buffer
+		reqBody := newBodyCapture(bodyConf, c.Request.Header)
+		if c.Request.Body != nil && c.Request.Body != http.NoBody {
+			c.Request.Body = &captureReadCloser{c.Request.Body, reqBody}
+		}
 
-		c.Request.Body = TeeReadCloser(c.Request.Body, &bufReq)
-		c.Writer = GinMultiWriter(c.Writer, &bufRsp)
+		w := &ginCaptureWriter{c.Writer, newBodyCapture(bodyConf, c.Writer.Header())}
+		c.Writer = w
 
 		c.Next()
+
+		c.Writer = w.ResponseWriter
 		stop := time.Since(start)
 		latency := int(math.Ceil(float64(stop.Nanoseconds()) / 1000000.0))
-		statusCode := c.Writer.Status()
-		clientIP := c.ClientIP()
 
-		entry := log.WithFields(logrus.Fields{
-			"statusCode": statusCode,
+		fields := logrus.Fields{
+			"statusCode": c.Writer.Status(),
 			"latency":    latency, // time to process
-			"clientIP":   clientIP,
+			"clientIP":   c.ClientIP(),
 			"userAgent":  c.Request.UserAgent(),
-		})
-
-		var msg bytes.Buffer
-		msg.WriteString(c.Request.Method)
-		msg.WriteString(" ")
-		msg.WriteString(c.Request.RequestURI)
-
-		reqLen := bufReq.Len()
-
-		if reqLen > 0 {
-			contentType := c.ContentType()
-			if contentType == binding.MIMEJSON || contentType == binding.MIMEPOSTForm /* || contentType == binding.MIMEMultipartPOSTForm */ {
-				msg.WriteString(" req:")
-
-				if reqLen > trimmedMsgLength {
-					msg.WriteString(fmt.Sprintf("[%d]", reqLen))
-					msg.Write(bufReq.Bytes()[:trimmedMsgLength-12])
-					msg.WriteString("...")
-				} else {
-					msg.Write(bufReq.Bytes())
-				}
-			} else {
-				msg.WriteString(fmt.Sprintf(" req: len=%d, content_type=%s", reqLen, contentType))
-			}
-		} else {
-			msg.WriteString(" req: len=0")
-		}
-
-		rspLen := bufRsp.Len()
-		if rspLen > 0 {
-			contentType := c.Writer.Header().Get("Content-Type")
-			if strings.HasPrefix(contentType, binding.MIMEJSON) {
-				msg.WriteString(" rsp:")
-
-				if rspLen > trimmedMsgLength {
-					msg.WriteString(fmt.Sprintf("[%d]", rspLen))
-					msg.Write(bufRsp.Bytes()[:trimmedMsgLength-12])
-					msg.WriteString("...")
-				} else {
-					msg.Write(bufRsp.Bytes())
-				}
-			} else {
-				msg.WriteString(fmt.Sprintf(" rsp: len=%d, content_type=%s", rspLen, contentType))
-			}
-		} else {
-			msg.WriteString(" rsp: len=0")
 		}
+		reqBody.setFields(fields, FieldReqBody, FieldReqLength, FieldReqContentType, int(c.Request.ContentLength))
+		w.body.setFields(fields, FieldRspBody, FieldRspLength, FieldRspContentType, c.Writer.Size())
 
-		entry.Debug(msg.String())
+		log.WithFields(ginRequestFields(c)).WithFields(fields).Log(conf.Level, c.Request.Method+" "+c.Request.RequestURI)
 	}
 }
@@ -0,0 +1,65 @@
+package qlog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestGinAPILoggerLevel(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name string
+		conf GinAPILoggerConfig
+		want logrus.Level
+	}{
+		{"default", GinAPILoggerConfig{}, logrus.DebugLevel},
+		{"info", GinAPILoggerConfig{Level: logrus.InfoLevel}, logrus.InfoLevel},
+		{"panic", GinAPILoggerConfig{Level: logrus.PanicLevel, LevelSet: true}, logrus.PanicLevel},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, hook := logtest.NewNullLogger()
+			logger.SetLevel(logrus.TraceLevel)
+			tt.conf.Logger = logrus.NewEntry(logger)
+
+			router := gin.New()
+			router.Use(GinAPILoggerWithConfig(tt.conf))
+			router.GET("/", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+
+			func() {
+				// logrus panics after logging at panic level
+				defer func() { recover() }()
+				router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+			}()
+
+			if e := hook.LastEntry(); e == nil || e.Level != tt.want {
+				t.Errorf("got %v, want an entry at %s level", e, tt.want)
+			}
+		})
+	}
+}
+
+func TestAccessLoggerSlowLevel(t *testing.T) {
+	tests := []struct {
+		name string
+		conf GinLoggerConfig
+		want logrus.Level
+	}{
+		{"default", GinLoggerConfig{}, logrus.WarnLevel},
+		{"error", GinLoggerConfig{SlowLevel: logrus.ErrorLevel}, logrus.ErrorLevel},
+		{"panic", GinLoggerConfig{SlowLevel: logrus.PanicLevel, SlowLevelSet: true}, logrus.PanicLevel},
+	}
+
+	for _, tt := range tests {
+		if got := newAccessLogger(tt.conf).conf.SlowLevel; got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package qlog
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -65,22 +66,37 @@ func (h *FileHook) Setup() error {
 		}
 
 		h.RotateCount = uint(v.GetInt(keyFileRotateCount))
+	}
+
+	w, err := newRotateWriter(fullPath, h.RotateTime, h.RotateMaxAge, h.RotateCount)
+	if err != nil {
+		return err
+	}
+	h.setWriter(w)
 
-		if h.writer, err = rotatelogs.New(fullPath+".%Y%m%d%H%M",
+	return nil
+}
+
+// newRotateWriter creates the log file of fullPath, it is rotated if rotateTime > 0
+func newRotateWriter(fullPath string, rotateTime time.Duration, maxAge time.Duration, count uint) (io.Writer, error) {
+	if rotateTime > 0 {
+		w, err := rotatelogs.New(fullPath+".%Y%m%d%H%M",
 			rotatelogs.WithLinkName(fullPath),
-			rotatelogs.WithMaxAge(h.RotateMaxAge),
-			rotatelogs.WithRotationTime(h.RotateTime),
-			rotatelogs.WithRotationCount(h.RotateCount),
-		); err != nil {
-			return fmt.Errorf("Create rotate log fail: %s", err)
-		}
-	} else {
-		if h.writer, err = os.Create(fullPath); err != nil {
-			return fmt.Errorf("Create log fail: %s", err)
+			rotatelogs.WithMaxAge(maxAge),
+			rotatelogs.WithRotationTime(rotateTime),
+			rotatelogs.WithRotationCount(count),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("Create rotate log fail: %s", err)
 		}
+		return w, nil
 	}
 
-	return nil
+	w, err := os.Create(fullPath)
+	if err != nil {
+		return nil, fmt.Errorf("Create log fail: %s", err)
+	}
+	return w, nil
 }
 
 var _InitFileHook = func() interface{} {
@@ -93,6 +109,10 @@ var _InitFileHook = func() interface{} {
 	cli.String(keyFileRotateMaxAge, "168h", "logger.file.rotate.maxag")
 	cli.String(keyFileRotateCount, "0", "logger.file.rotate.count")
 
+	registerConfigKey(keyFileRotateTime, checkDuration)
+	registerConfigKey(keyFileRotateMaxAge, checkDuration)
+	registerConfigKey(keyFileRotateCount, checkUint)
+
 	registerHook("file", reflect.TypeOf(FileHook{}))
 
 	return nil
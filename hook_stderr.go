@@ -19,7 +19,7 @@ type StderrHook struct {
 func (h *StderrHook) Setup() error {
 	h.baseSetup()
 
-	h.writer = os.Stderr
+	h.setWriter(os.Stderr)
 
 	return nil
 }
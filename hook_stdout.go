@@ -19,7 +19,7 @@ type StdoutHook struct {
 func (h *StdoutHook) Setup() error {
 	h.baseSetup()
 
-	h.writer = os.Stdout
+	h.setWriter(os.Stdout)
 
 	return nil
 }
@@ -78,7 +78,7 @@ func (h *UDPHook) Setup() (err error) {
 		return err
 	}
 
-	h.b.writer = conn
+	h.b.setWriter(conn)
 
 	return nil
 }
@@ -87,6 +87,9 @@ var _InitUDPHook = func() interface{} {
 	cli.Bool(keyUDPEnabled, false, "logger.udp.enabled")
 	cli.String(keyUDPLevel, "", "logger.udp.level") // DONOT set default level in pflag
 
+	registerConfigKey(keyUDPHost, nil)
+	registerConfigKey(keyUDPUUID, nil)
+
 	registerHook("udp", reflect.TypeOf(UDPHook{}))
 	return nil
 }()
@@ -1,10 +1,13 @@
 package qlog
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"reflect"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/sirupsen/logrus"
 )
@@ -17,6 +20,19 @@ func getLogLevels(baseLevel logrus.Level) (level []logrus.Level) {
 	return
 }
 
+// getHookLevel returns the level of a hook, ok is false if the hook level is not set
+func getHookLevel(name string) (level logrus.Level, ok bool, err error) {
+	l := v.GetString(strings.Join([]string{"logger", name, "level"}, "."))
+	if l == "" {
+		return level, false, nil
+	}
+
+	if level, err = logrus.ParseLevel(l); err != nil {
+		return level, false, err
+	}
+	return level, true, nil
+}
+
 // HookSetuper is the base interface a qlog hook must implement
 type HookSetuper interface {
 	Setup() error
@@ -30,16 +46,42 @@ type BaseHook struct {
 	formatter logrus.Formatter
 	logLevels []logrus.Level
 	writer    io.Writer
+	state     *hookState
 }
 
+const (
+	maxPooledBufferSize = 64 * 1024 // larger buffers are not pooled to release memory of large entries
+)
+
+// gBufferPool is the pool of buffers formatters write to, logrus only sets entry.Buffer after hooks are fired
+var gBufferPool = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+
 // Fire output message to hook writer
 func (h *BaseHook) Fire(e *logrus.Entry) error {
 	// fmt.Println("fire:", h.Name)
+	if e.Buffer == nil {
+		buf := gBufferPool.Get().(*bytes.Buffer)
+		buf.Reset()
+		e.Buffer = buf
+		defer func() {
+			e.Buffer = nil
+			if buf.Cap() <= maxPooledBufferSize {
+				gBufferPool.Put(buf)
+			}
+		}()
+	}
+
 	dataBytes, err := h.formatter.Format(e)
 	if err != nil {
+		h.state.formatErrors.Add(1)
+		h.state.dropped.Add(1)
 		return err
 	}
-	_, err = h.writer.Write(dataBytes)
+	n, err := h.writer.Write(dataBytes)
+	h.state.bytes.Add(uint64(n))
+	if err != nil && n == 0 {
+		h.state.dropped.Add(1)
+	}
 
 	return err
 }
@@ -49,23 +91,38 @@ func (h *BaseHook) Levels() []logrus.Level {
 	return h.logLevels
 }
 
+// writerFormatter is a formatter depending on the writer, e.g. detecting terminals for colors
+type writerFormatter interface {
+	forWriter(w io.Writer) logrus.Formatter
+}
+
+// setWriter sets the hook writer, the formatter is adapted to it as formatters may be shared by hooks
+func (h *BaseHook) setWriter(w io.Writer) {
+	h.writer = w
+	if f, ok := h.formatter.(writerFormatter); ok {
+		h.formatter = f.forWriter(w)
+	}
+}
+
 func (h *BaseHook) baseSetup() {
+	h.state = getHookState(h.Name)
+
 	// setup levels
 	var level = qLogger.Level
 	var err error
-	if h.Level = v.GetString(strings.Join([]string{"logger", h.Name, "level"}, ".")); h.Level != "" {
-		if level, err = logrus.ParseLevel(h.Level); err != nil {
-			fmt.Printf("[qlog] setup hook(%s), parse level fail:%s\n", h.Name, err)
-			level = qLogger.Level
-		}
+	if l, ok, err := getHookLevel(h.Name); err != nil {
+		getHookState(h.Name).fail(fmt.Errorf("setup level fail:%s", err))
+	} else if ok {
+		level = l
 	}
+	h.Level = v.GetString(strings.Join([]string{"logger", h.Name, "level"}, "."))
 
 	h.logLevels = getLogLevels(level)
 
 	// setup formatters
 	if hookFormatterName := v.GetString(strings.Join([]string{"logger", h.Name, "formatter", "name"}, ".")); hookFormatterName != "" {
 		if h.formatter, err = newFormatter(hookFormatterName, strings.Join([]string{"logger", h.Name, "formatter", "opts"}, ".")); err != nil {
-			fmt.Printf("[qlog] setup hook(%s) formatter(%s) fail:%s\n", h.Name, hookFormatterName, err)
+			getHookState(h.Name).fail(fmt.Errorf("setup formatter(%s) fail:%s", hookFormatterName, err))
 			h.formatter = qLogger.Formatter
 		}
 	} else {
@@ -83,13 +140,23 @@ func registerHook(name string, typ reflect.Type) {
 	}
 }
 
+// registeredHookNames returns sorted names of registered hooks
+func registeredHookNames() []string {
+	names := make([]string, 0, len(gRegisteredHooks))
+	for name := range gRegisteredHooks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 func newHook(name string) (logrus.Hook, error) {
 	var err error
 	var typ reflect.Type
 	var ok bool
 
 	if typ, ok = gRegisteredHooks[name]; !ok {
-		return nil, fmt.Errorf("[qlog] hook name(%s) not registered", name)
+		return nil, fmt.Errorf("hook name(%s) not registered", name)
 	}
 
 	h := reflect.New(typ)
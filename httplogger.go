@@ -0,0 +1,219 @@
+package qlog
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// HTTPMiddlewareOptions are options of HTTPMiddleware
+type HTTPMiddlewareOptions struct {
+	// GinLoggerConfig has the same meaning as in GinLoggerWithConfig, the default logger has field pkg=http,
+	// field route is always empty as net/http has no route template
+	GinLoggerConfig
+
+	// RequestID reads or generates the request id and parses traceparent like GinRequestID,
+	// handlers get the request-scoped entry by FromContext(r.Context())
+	RequestID bool
+
+	// Body logs request and response bodies as fields reqBody and rspBody like GinAPILogger
+	Body bool
+	BodyCaptureConfig
+}
+
+// responseWriter records status and size of a response and captures its body, it is wrapped by wrapResponseWriter
+// to keep http.Flusher and http.Hijacker of the wrapped writer
+type responseWriter struct {
+	http.ResponseWriter
+	status   int
+	size     int
+	hijacked bool
+	body     *bodyCapture // nil if bodies are not captured
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.status == 0 && code >= 200 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	n, err := w.ResponseWriter.Write(p)
+	w.size += n
+	if w.body != nil {
+		w.body.Write(p[:n])
+	}
+	return n, err
+}
+
+// writerOnly hides ReadFrom of a writer to io.Copy
+type writerOnly struct {
+	io.Writer
+}
+
+func (w *responseWriter) ReadFrom(r io.Reader) (int64, error) {
+	rf, ok := w.ResponseWriter.(io.ReaderFrom)
+	if !ok || w.body != nil {
+		return io.Copy(writerOnly{w}, r)
+	}
+
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	n, err := rf.ReadFrom(r)
+	w.size += int(n)
+	return n, err
+}
+
+// flush flushes the wrapped writer, flushed responses are streamed and not captured
+func (w *responseWriter) flush() {
+	if w.body != nil {
+		w.body.stop()
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+func (w *responseWriter) hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.hijacked = true
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+// responseWriters implementing http.Flusher or http.Hijacker as the wrapped writer
+type (
+	flushWriter       struct{ *responseWriter }
+	hijackWriter      struct{ *responseWriter }
+	flushHijackWriter struct{ *responseWriter }
+)
+
+func (w flushWriter) Flush() { w.flush() }
+
+func (w hijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) { return w.hijack() }
+
+func (w flushHijackWriter) Flush() { w.flush() }
+
+func (w flushHijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) { return w.hijack() }
+
+// wrapResponseWriter returns rw as a http.ResponseWriter which implements http.Flusher and http.Hijacker
+// only if the writer wrapped by rw does, so that handlers can check them by type assertions
+func wrapResponseWriter(rw *responseWriter) http.ResponseWriter {
+	_, flusher := rw.ResponseWriter.(http.Flusher)
+	_, hijacker := rw.ResponseWriter.(http.Hijacker)
+
+	switch {
+	case flusher && hijacker:
+		return flushHijackWriter{rw}
+	case flusher:
+		return flushWriter{rw}
+	case hijacker:
+		return hijackWriter{rw}
+	}
+	return rw
+}
+
+// Unwrap returns the wrapped writer for http.ResponseController
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *responseWriter) statusCode() int {
+	switch {
+	case w.status != 0:
+		return w.status
+	case w.hijacked:
+		return http.StatusSwitchingProtocols
+	}
+	return http.StatusOK
+}
+
+func remoteIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// HTTPMiddleware is the qlog logger for net/http, it logs requests served by next like GinLogger,
+// and optionally sets request id like GinRequestID and captures bodies like GinAPILogger
+func HTTPMiddleware(next http.Handler, opts HTTPMiddlewareOptions) http.Handler {
+	if opts.Logger == nil {
+		opts.Logger = logrus.StandardLogger().WithField("pkg", "http")
+	}
+
+	l := newAccessLogger(opts.GinLoggerConfig)
+	log := opts.Logger
+	bodyConf := opts.BodyCaptureConfig.withDefaults()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entry := log
+
+		if opts.RequestID {
+			rid := requestID(r.Header)
+			fields := requestFields(r.Header, rid)
+
+			r = r.WithContext(NewContext(r.Context(), log.WithFields(fields)))
+			w.Header().Set(HeaderRequestID, rid)
+			entry = entry.WithFields(fields)
+		}
+
+		path := r.URL.Path
+		if l.skipped(path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rw := &responseWriter{ResponseWriter: w}
+
+		var reqBody *bodyCapture
+		if opts.Body {
+			reqBody = newBodyCapture(bodyConf, r.Header)
+			if r.Body != nil && r.Body != http.NoBody {
+				r.Body = &captureReadCloser{r.Body, reqBody}
+			}
+			rw.body = newBodyCapture(bodyConf, w.Header())
+		}
+
+		start := time.Now()
+		next.ServeHTTP(wrapResponseWriter(rw), r)
+
+		user, _, _ := r.BasicAuth()
+		info := &accessInfo{
+			start:      start,
+			method:     r.Method,
+			path:       path,
+			query:      r.URL.RawQuery,
+			requestURI: requestURI(r),
+			proto:      r.Proto,
+			user:       user,
+			status:     rw.statusCode(),
+			latency:    time.Since(start),
+			clientIP:   remoteIP(r),
+			userAgent:  r.UserAgent(),
+			referer:    r.Referer(),
+			dataLength: rw.size,
+			reqHeader:  r.Header,
+			rspHeader:  w.Header(),
+		}
+
+		if opts.Body {
+			fields := make(logrus.Fields, 4)
+			reqBody.setFields(fields, FieldReqBody, FieldReqLength, FieldReqContentType, int(r.ContentLength))
+			rw.body.setFields(fields, FieldRspBody, FieldRspLength, FieldRspContentType, rw.size)
+			entry = entry.WithFields(fields)
+		}
+
+		l.log(entry, info)
+	})
+}
@@ -0,0 +1,244 @@
+package qlog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// LevelConfig is the runtime level config of qlog
+type LevelConfig struct {
+	Level  string            `json:"level,omitempty"`  // default level
+	Hooks  map[string]string `json:"hooks,omitempty"`  // hook name to level, empty level means default level
+	Levels []*LevelOverride  `json:"levels,omitempty"` // level overrides, nil means unchanged, empty means clear
+}
+
+var (
+	gLevelMu     sync.Mutex
+	gDispatcher  *dispatchHook // nil if no hook is activated
+	gRevertTimer *time.Timer
+	gRevertConf  *LevelConfig // config to restore when gRevertTimer fires
+)
+
+// setDispatchHook installs d as the only hook of qLogger, pending level revert is cancelled as config is reloaded
+func setDispatchHook(d *dispatchHook) {
+	gLevelMu.Lock()
+	defer gLevelMu.Unlock()
+
+	cancelLevelRevert()
+	if gDispatcher != nil {
+		gDispatcher.sampler.close()
+	}
+	gDispatcher = d
+
+	if d == nil {
+		qLogger.ReplaceHooks(make(logrus.LevelHooks))
+		return
+	}
+
+	hooks := make(logrus.LevelHooks)
+	hooks.Add(d)
+	qLogger.ReplaceHooks(hooks)
+}
+
+func cancelLevelRevert() {
+	if gRevertTimer != nil {
+		gRevertTimer.Stop()
+		gRevertTimer = nil
+		gRevertConf = nil
+	}
+}
+
+// GetLevelConfig returns current levels of qlog
+func GetLevelConfig() LevelConfig {
+	gLevelMu.Lock()
+	defer gLevelMu.Unlock()
+
+	return getLevelConfig()
+}
+
+func getLevelConfig() LevelConfig {
+	d := gDispatcher
+	if d == nil {
+		return LevelConfig{Level: qLogger.GetLevel().String()}
+	}
+
+	conf := LevelConfig{
+		Level:  d.filter.getLevel().String(),
+		Hooks:  make(map[string]string, len(d.hooks)),
+		Levels: d.filter.getOverrides(),
+	}
+
+	if conf.Levels == nil {
+		conf.Levels = make([]*LevelOverride, 0)
+	}
+
+	for _, h := range d.hooks {
+		conf.Hooks[h.name] = h.levelString()
+	}
+
+	return conf
+}
+
+// SetLevelConfig changes levels in conf at runtime, empty fields are unchanged.
+// If revert > 0, levels are restored after revert, a later SetLevelConfig without revert cancels the restore.
+// Changes are lost if the config file is reloaded.
+func SetLevelConfig(conf LevelConfig, revert time.Duration) error {
+	gLevelMu.Lock()
+	defer gLevelMu.Unlock()
+
+	prev := getLevelConfig()
+
+	if err := setLevelConfig(conf); err != nil {
+		return err
+	}
+
+	if revert <= 0 {
+		cancelLevelRevert()
+		return nil
+	}
+
+	// keep the config before the first change if a revert is pending
+	if gRevertConf == nil {
+		gRevertConf = &prev
+	} else {
+		gRevertTimer.Stop()
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(revert, func() {
+		gLevelMu.Lock()
+		defer gLevelMu.Unlock()
+
+		if gRevertTimer != timer { // cancelled or replaced
+			return
+		}
+
+		if err := setLevelConfig(*gRevertConf); err != nil {
+			reportError(fmt.Errorf("revert levels fail:%s", err))
+		}
+		gRevertTimer = nil
+		gRevertConf = nil
+	})
+	gRevertTimer = timer
+
+	return nil
+}
+
+// setLevelConfig checks all levels in conf before any change, gLevelMu must be held
+func setLevelConfig(conf LevelConfig) error {
+	var err error
+	var level logrus.Level
+
+	d := gDispatcher
+
+	if len(conf.Level) > 0 {
+		if level, err = logrus.ParseLevel(conf.Level); err != nil {
+			return err
+		}
+	}
+
+	if d == nil {
+		if len(conf.Hooks) > 0 || conf.Levels != nil {
+			return errors.New("no activate log hook")
+		}
+		if len(conf.Level) > 0 {
+			qLogger.SetLevel(level)
+		}
+		return nil
+	}
+
+	hookLevels := make(map[*hookEntry]int32, len(conf.Hooks))
+	for name, l := range conf.Hooks {
+		h := d.getHook(name)
+		if h == nil {
+			return fmt.Errorf("hook(%s) is not activated", name)
+		}
+
+		hookLevels[h] = levelInherit
+		if len(l) > 0 {
+			hl, err := logrus.ParseLevel(l)
+			if err != nil {
+				return fmt.Errorf("hook(%s): %s", name, err)
+			}
+			hookLevels[h] = int32(hl)
+		}
+	}
+
+	if conf.Levels != nil {
+		// do not modify the caller's overrides, nil overrides are rejected by parseLevelOverrides
+		overrides := make([]*LevelOverride, len(conf.Levels))
+		for i, o := range conf.Levels {
+			if o != nil {
+				c := *o
+				overrides[i] = &c
+			}
+		}
+		if err = parseLevelOverrides(overrides); err != nil {
+			return err
+		}
+		d.filter.setOverrides(overrides)
+	}
+
+	if len(conf.Level) > 0 {
+		d.filter.setLevel(level)
+	}
+
+	for h, l := range hookLevels {
+		h.level.Store(l)
+	}
+
+	qLogger.SetLevel(d.filter.maxLevel())
+
+	return nil
+}
+
+type levelRequest struct {
+	LevelConfig
+	Revert string `json:"revert,omitempty"` // duration to restore levels, e.g. "10m"
+}
+
+// LevelHandler returns an http.Handler to get (GET) or change (PUT) levels at runtime,
+// PUT body is a json LevelConfig with an optional "revert" duration, e.g.
+//
+//	{"level": "trace", "hooks": {"file": "trace"}, "revert": "10m"}
+func LevelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			var req levelRequest
+			var revert time.Duration
+			var err error
+
+			if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			if len(req.Revert) > 0 {
+				if revert, err = time.ParseDuration(req.Revert); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+
+			if err = SetLevelConfig(req.LevelConfig, revert); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(GetLevelConfig())
+	})
+}
@@ -0,0 +1,131 @@
+package qlog
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	keyLevels = "logger.levels"
+)
+
+// LevelOverride overrides the default level for entries with field value or entries from a caller package,
+// e.g. {Field: "pkg", Value: "gin", Level: "warn"} or {Caller: "github.com/acme/db", Level: "trace"}
+type LevelOverride struct {
+	Field  string `json:"field,omitempty"`
+	Value  string `json:"value,omitempty"`
+	Caller string `json:"caller,omitempty"` // caller package path prefix
+	Level  string `json:"level"`
+
+	level logrus.Level
+}
+
+// match checks if e matches the override, callerSkips are frames skipped when looking for the caller
+func (o *LevelOverride) match(e *logrus.Entry, callerSkips []string) bool {
+	if len(o.Field) > 0 {
+		fv, ok := e.Data[o.Field]
+		return ok && fmt.Sprint(fv) == o.Value
+	}
+
+	caller := entryCaller(e, callerSkips)
+	return caller != nil && funcInPackages(caller.Function, []string{o.Caller})
+}
+
+// levelFilter drops entries by default level and level overrides before hooks are fired
+type levelFilter struct {
+	mu        sync.RWMutex
+	level     logrus.Level
+	overrides []*LevelOverride
+}
+
+func newLevelFilter(level logrus.Level, overrides []*LevelOverride) (*levelFilter, error) {
+	if err := parseLevelOverrides(overrides); err != nil {
+		return nil, err
+	}
+
+	return &levelFilter{level: level, overrides: overrides}, nil
+}
+
+// parseLevelOverrides checks overrides and parses their levels
+func parseLevelOverrides(overrides []*LevelOverride) (err error) {
+	for i, o := range overrides {
+		if o == nil {
+			return fmt.Errorf("%s[%d]: override is null", keyLevels, i)
+		}
+		if len(o.Field) == 0 && len(o.Caller) == 0 {
+			return fmt.Errorf("%s[%d]: field or caller must be set", keyLevels, i)
+		}
+		if o.level, err = logrus.ParseLevel(o.Level); err != nil {
+			return fmt.Errorf("%s[%d]: %s", keyLevels, i, err)
+		}
+	}
+	return nil
+}
+
+func (f *levelFilter) setLevel(level logrus.Level) {
+	f.mu.Lock()
+	f.level = level
+	f.mu.Unlock()
+}
+
+// setOverrides replaces overrides, they must be parsed by parseLevelOverrides
+func (f *levelFilter) setOverrides(overrides []*LevelOverride) {
+	f.mu.Lock()
+	f.overrides = overrides
+	f.mu.Unlock()
+}
+
+func (f *levelFilter) getLevel() logrus.Level {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.level
+}
+
+func (f *levelFilter) getOverrides() []*LevelOverride {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.overrides
+}
+
+// maxLevel returns the most verbose level among the default level and overrides, it is the level of logrus logger
+func (f *levelFilter) maxLevel() logrus.Level {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	level := f.level
+	for _, o := range f.overrides {
+		if o.level > level {
+			level = o.level
+		}
+	}
+	return level
+}
+
+func (f *levelFilter) allowed(e *logrus.Entry, callerSkips []string) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if len(f.overrides) == 0 {
+		return e.Level <= f.level
+	}
+
+	for _, o := range f.overrides {
+		if o.match(e, callerSkips) {
+			return e.Level <= o.level
+		}
+	}
+
+	return e.Level <= f.level
+}
+
+func getLevelOverrides() ([]*LevelOverride, error) {
+	var overrides []*LevelOverride
+
+	if err := v.UnmarshalKey(keyLevels, &overrides); err != nil {
+		return nil, fmt.Errorf("parse %s fail: %s", keyLevels, err)
+	}
+
+	return overrides, nil
+}
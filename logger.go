@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"os"
 	"strings"
+	"sync/atomic"
 
 	"github.com/spf13/pflag"
 
@@ -38,6 +39,9 @@ var (
 	// viper
 	v = viper.New()
 
+	// env key of a config key, e.g. LOGGER_LEVEL for logger.level
+	gEnvKeyReplacer = strings.NewReplacer(".", "_")
+
 	// DefaultLogger : default logger object
 	qLogger = logrus.StandardLogger()
 
@@ -62,11 +66,16 @@ func setDefault() {
 	v.SetDefault(keyReportCaller, false)
 	v.SetDefault(keyDefaultLevel, "debug")
 	v.SetDefault(keyDefaultFormatterName, "text")
+	v.SetDefault(keySamplingSummary, "1m")
+	v.SetDefault(keyRedactMode, redactModeMask)
+	v.SetDefault(keyRedactMask, "******")
+	v.SetDefault(keyErrorsExpand, false)
 }
 
 func initFlags() error {
 	cli.Bool(keyReportCaller, false, "logger.reportcaller")
 	cli.String(keyDefaultLevel, "error", "logger.level")
+	registerConfigKey(keyDefaultLevel, checkLevel)
 	cli.String(keyDefaultFormatterName, "text", "logger.formatter.name")
 
 	cli.StringSliceVar(&qLoggerConfig.Path, keyConfigPath, []string{".", "./conf", "/etc/qlog"}, "logger.config.path")
@@ -85,7 +94,7 @@ func initViper() error {
 
 	// read from env
 	v.AutomaticEnv()
-	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.SetEnvKeyReplacer(gEnvKeyReplacer)
 
 	// set default
 	setDefault()
@@ -109,11 +118,11 @@ func initViper() error {
 		default:
 			return err
 		}
-	} else {
+	} else if !gConfigOnly {
 		// watch configs changes
 		v.WatchConfig()
 		v.OnConfigChange(func(e fsnotify.Event) {
-			fmt.Println("[qlog] config changed: ", e.Name)
+			diagf("config changed: %s", e.Name)
 			resetLogger()
 		})
 	}
@@ -125,7 +134,7 @@ func initViper() error {
 
 func resetLogger() {
 	if err := configLogger(); err != nil {
-		fmt.Printf("[qlog] reload config fail:%s, changes may not take effect!", err)
+		reportError(fmt.Errorf("reload config fail:%s, changes may not take effect", err))
 	}
 }
 
@@ -133,20 +142,24 @@ func getDefaultFormatter() (logrus.Formatter, error) {
 	return newFormatter(v.GetString(keyDefaultFormatterName), keyDefaultFormatterOpts)
 }
 
-func getActivateHooks() (logrus.LevelHooks, error) {
+func getActivateHooks() ([]*hookEntry, error) {
 	var err error
 	var hook logrus.Hook
-	var activateHooks = make(logrus.LevelHooks)
+	var activateHooks = make([]*hookEntry, 0)
 
-	for name := range gRegisteredHooks {
+	for _, name := range registeredHookNames() {
 		n := strings.Join([]string{"logger", name, "enabled"}, ".")
-		//fmt.Println("initHooks", n, v.GetBool(n))
+		state := getHookState(name)
+		state.enabled.Store(v.GetBool(n))
+		state.active.Store(false)
+
 		if v.GetBool(n) == true {
 			if hook, err = newHook(name); err != nil {
-				fmt.Printf("[qlog] init hook(%s) error:%s\n", name, err)
+				state.fail(fmt.Errorf("init error:%s", err))
 				continue
 			}
-			activateHooks.Add(hook)
+			state.succeed()
+			activateHooks = append(activateHooks, newHookEntry(state, hook))
 		}
 	}
 
@@ -167,7 +180,34 @@ func configLogger() error {
 	if err != nil {
 		return fmt.Errorf("get default log level error: %s", err)
 	}
-	qLogger.SetLevel(level)
+
+	overrides, err := getLevelOverrides()
+	if err != nil {
+		return err
+	}
+
+	filter, err := newLevelFilter(level, overrides)
+	if err != nil {
+		return fmt.Errorf("get level overrides error: %s", err)
+	}
+
+	samplingRules, samplingSummary, err := getSamplingRules()
+	if err != nil {
+		return err
+	}
+
+	redactor, err := getRedactor()
+	if err != nil {
+		return err
+	}
+
+	expander, err := getErrorExpander()
+	if err != nil {
+		return err
+	}
+
+	// entries are filtered by levelFilter before hooks fire, logrus level is the most verbose one
+	qLogger.SetLevel(filter.maxLevel())
 
 	formatter, err := getDefaultFormatter()
 	if err != nil {
@@ -175,21 +215,48 @@ func configLogger() error {
 	}
 	qLogger.SetFormatter(formatter)
 
+	setupSlog()
+
+	// a broken access log should not stop the application log
+	if err = setupAccessLog(); err != nil {
+		reportError(err)
+	}
+
 	// SetLevel and SetFormatter must be called before getActivateHooks.
 	hooks, err := getActivateHooks()
 
 	if err != nil {
-		fmt.Printf("[qlog] get hooks error: %s\n", err)
-		qLogger.ReplaceHooks(nil)
+		reportError(fmt.Errorf("get hooks error: %s, log to stderr", err))
+		setDispatchHook(nil)
+		if redactor != nil || expander != nil {
+			qLogger.AddHook(&stderrHook{redactor: redactor, expander: expander})
+		}
 		qLogger.SetOutput(os.Stderr)
+		qLogger.SetLevel(level)
 		return nil
 	}
 
 	qLogger.SetOutput(ioutil.Discard)
-	qLogger.ReplaceHooks(hooks)
+	var s *sampler
+	if len(samplingRules) > 0 {
+		s = newSampler(samplingRules, samplingSummary)
+	}
+
+	setDispatchHook(newDispatchHook(hooks, filter, s, redactor, expander, v.GetStringSlice(keyContextKeys), getCallerSkips()))
+	for _, h := range hooks {
+		h.state.active.Store(true)
+	}
 	return nil
 }
 
+// gConfigOnly is set by env QLOG_CONFIG_ONLY=1, which is set by package configonly for tools like qlogctl.
+// The config is loaded to check or dump it, the logger is not configured and the config file is not watched,
+// so that no log file or connection is opened and a bad config doesn't panic.
+var gConfigOnly = os.Getenv("QLOG_CONFIG_ONLY") == "1"
+
+// gConfigured is set after the logger is configured in init, prettyfiers used by reloaded config must be registered
+var gConfigured atomic.Bool
+
 func init() {
 	var err error
 
@@ -205,7 +272,12 @@ func init() {
 		panic(fmt.Sprint("[qlog] init viper error:", err))
 	}
 
+	if gConfigOnly {
+		return
+	}
+
 	if err = configLogger(); err != nil {
 		panic(fmt.Sprint("[qlog] configLogger fail:", err))
 	}
+	gConfigured.Store(true)
 }
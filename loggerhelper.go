@@ -4,6 +4,7 @@ import (
 	"os"
 	"os/user"
 	"path/filepath"
+	"reflect"
 	"strings"
 )
 
@@ -13,6 +14,9 @@ var (
 	gProgram  = filepath.Base(os.Args[0])
 	gHost     = "unknownhost"
 	gUserName = "unknownuser"
+
+	// import path of qlog
+	gPkgPath = reflect.TypeOf(BaseHook{}).PkgPath()
 )
 
 // shortHostname returns its argument, truncating at the first period.
@@ -58,3 +62,15 @@ func filterLoggerFlags(args []string, keep bool) []string {
 
 	return rlt
 }
+
+// funcInPackages checks if a function name returned by runtime.Frame is in any of pkgs or their sub packages
+func funcInPackages(function string, pkgs []string) bool {
+	for _, pkg := range pkgs {
+		if len(function) > len(pkg) && strings.HasPrefix(function, pkg) {
+			if c := function[len(pkg)]; c == '.' || c == '/' {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package qlog
+
+import (
+	"bufio"
+	"expvar"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// entries fired to hooks per level, indexed by logrus.Level
+	gLevelCounts [logrus.TraceLevel + 1]atomic.Uint64
+
+	// entries dropped by sampling
+	gSuppressedCount atomic.Uint64
+)
+
+// HookMetrics are counters of a hook
+type HookMetrics struct {
+	Fired           uint64 `json:"fired"`           // entries fired to the hook
+	Failed          uint64 `json:"failed"`          // fire errors
+	Dropped         uint64 `json:"dropped"`         // entries not written at all, by formatter or write errors
+	BytesWritten    uint64 `json:"bytesWritten"`    // bytes written
+	FormatterErrors uint64 `json:"formatterErrors"` // formatter errors
+}
+
+// Metrics are counters of qlog since the program started
+type Metrics struct {
+	Entries    map[string]uint64      `json:"entries"`    // entries fired to hooks per level
+	Suppressed uint64                 `json:"suppressed"` // entries dropped by sampling
+	Hooks      map[string]HookMetrics `json:"hooks"`
+	Errors     uint64                 `json:"errors"` // qlog internal errors
+}
+
+// GetMetrics returns a snapshot of qlog counters
+func GetMetrics() Metrics {
+	m := Metrics{
+		Entries: make(map[string]uint64, len(logrus.AllLevels)),
+		Hooks:   make(map[string]HookMetrics, len(gRegisteredHooks)),
+		Errors:  ErrorCount(),
+
+		Suppressed: gSuppressedCount.Load(),
+	}
+
+	for _, level := range logrus.AllLevels {
+		m.Entries[level.String()] = gLevelCounts[level].Load()
+	}
+
+	for _, name := range registeredHookNames() {
+		s := getHookState(name)
+		m.Hooks[name] = HookMetrics{
+			Fired:           s.fired.Load(),
+			Failed:          s.failed.Load(),
+			Dropped:         s.dropped.Load(),
+			BytesWritten:    s.bytes.Load(),
+			FormatterErrors: s.formatErrors.Load(),
+		}
+	}
+
+	return m
+}
+
+// MetricsHandler returns an http.Handler which writes qlog counters in Prometheus text format
+func MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m := GetMetrics()
+		names := registeredHookNames()
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		b := bufio.NewWriter(w)
+		defer b.Flush()
+
+		writeHeader := func(name, help string) {
+			fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+		}
+
+		writeHeader("qlog_entries_total", "Log entries fired to hooks by level.")
+		for _, level := range logrus.AllLevels {
+			fmt.Fprintf(b, "qlog_entries_total{level=%q} %d\n", level.String(), m.Entries[level.String()])
+		}
+
+		writeHeader("qlog_entries_suppressed_total", "Log entries dropped by sampling.")
+		fmt.Fprintf(b, "qlog_entries_suppressed_total %d\n", m.Suppressed)
+
+		hookCounters := []struct {
+			name  string
+			help  string
+			value func(HookMetrics) uint64
+		}{
+			{"qlog_hook_fired_total", "Log entries fired to the hook.", func(h HookMetrics) uint64 { return h.Fired }},
+			{"qlog_hook_failed_total", "Hook fire errors.", func(h HookMetrics) uint64 { return h.Failed }},
+			{"qlog_hook_dropped_total", "Log entries not written by the hook.", func(h HookMetrics) uint64 { return h.Dropped }},
+			{"qlog_hook_written_bytes_total", "Bytes written by the hook.", func(h HookMetrics) uint64 { return h.BytesWritten }},
+			{"qlog_formatter_errors_total", "Formatter errors of the hook.", func(h HookMetrics) uint64 { return h.FormatterErrors }},
+		}
+
+		for _, c := range hookCounters {
+			writeHeader(c.name, c.help)
+			for _, name := range names {
+				fmt.Fprintf(b, "%s{hook=%q} %d\n", c.name, name, c.value(m.Hooks[name]))
+			}
+		}
+
+		writeHeader("qlog_errors_total", "qlog internal errors.")
+		fmt.Fprintf(b, "qlog_errors_total %d\n", m.Errors)
+	})
+}
+
+var _InitMetrics = func() interface{} {
+	expvar.Publish("qlog", expvar.Func(func() interface{} {
+		return GetMetrics()
+	}))
+	return nil
+}()
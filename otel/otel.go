@@ -0,0 +1,32 @@
+// Package qlogotel adds OpenTelemetry span context carried by contexts to qlog entries
+package qlogotel
+
+import (
+	"context"
+
+	"github.com/kkkbird/qlog"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// FieldTraceFlags is the field name of W3C trace flags, e.g. 01 for sampled spans
+const FieldTraceFlags = "traceFlags"
+
+// Extract returns traceId, spanId and traceFlags fields of the span context carried by ctx
+func Extract(ctx context.Context) logrus.Fields {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return nil
+	}
+
+	return logrus.Fields{
+		qlog.FieldTraceID: sc.TraceID().String(),
+		qlog.FieldSpanID:  sc.SpanID().String(),
+		FieldTraceFlags:   sc.TraceFlags().String(),
+	}
+}
+
+// Register adds span context fields to entries carrying a context, e.g. qlog.WithContext(ctx).Info("hello")
+func Register() {
+	qlog.RegisterContextExtractor(Extract)
+}
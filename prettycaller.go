@@ -0,0 +1,167 @@
+package qlog
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+)
+
+// CallerPrettyfier returns function and file of a caller shown by formatters, an empty value is omitted
+type CallerPrettyfier = func(*runtime.Frame) (function string, file string)
+
+var gPrettyCallerMu sync.RWMutex
+
+// RegisterCallerPrettyfier registers a prettyfier, formatters use it by option prettycaller=name.
+// The logger is configured in init before prettyfiers are registered by applications, formatters look up names which
+// are not registered yet when they format entries, an error is reported if a name is still not registered then.
+// Names must be registered when the config is reloaded, and Validate reports names which are not registered.
+func RegisterCallerPrettyfier(name string, fn CallerPrettyfier) {
+	gPrettyCallerMu.Lock()
+	defer gPrettyCallerMu.Unlock()
+
+	gPrettyCallFuncMap[name] = fn
+}
+
+func registeredPrettyfier(name string) (CallerPrettyfier, bool) {
+	gPrettyCallerMu.RLock()
+	defer gPrettyCallerMu.RUnlock()
+
+	fn, ok := gPrettyCallFuncMap[name]
+	return fn, ok
+}
+
+// getCallerPrettyfier returns the prettyfier registered as name, or a template prettyfier if name is a template
+func getCallerPrettyfier(name string) (CallerPrettyfier, error) {
+	if strings.Contains(name, "{{") {
+		return newTemplatePrettyfier(name)
+	}
+
+	if fn, ok := registeredPrettyfier(name); ok {
+		return fn, nil
+	}
+	return nil, fmt.Errorf("unsupported pretty func:%s", name)
+}
+
+// lookupCallerPrettyfier is getCallerPrettyfier for formatters. When the logger is configured in init, a name not
+// registered yet is looked up when entries are formatted, callers are not changed and an error is reported once
+// until it is registered. Names not registered after init are errors.
+func lookupCallerPrettyfier(name string) (CallerPrettyfier, error) {
+	fn, err := getCallerPrettyfier(name)
+	if err == nil || strings.Contains(name, "{{") || gConfigured.Load() {
+		return fn, err
+	}
+
+	var reported atomic.Bool
+	return func(caller *runtime.Frame) (function string, file string) {
+		if fn, ok := registeredPrettyfier(name); ok {
+			return fn(caller)
+		}
+		if !reported.Swap(true) {
+			reportError(fmt.Errorf("%s, callers are not prettified until it is registered", err))
+		}
+		return caller.Function, caller.File + ":" + strconv.Itoa(caller.Line)
+	}, nil
+}
+
+// funcPackage returns the package path of a function, e.g. github.com/a/b for github.com/a/b.(*T).M
+func funcPackage(function string) string {
+	slash := strings.LastIndexByte(function, '/')
+	if dot := strings.IndexByte(function[slash+1:], '.'); dot >= 0 {
+		return function[:slash+1+dot]
+	}
+	return function
+}
+
+// shortFunc returns a function name with the last element of its package path, e.g. b.(*T).M
+func shortFunc(function string) string {
+	return function[strings.LastIndexByte(function, '/')+1:]
+}
+
+var (
+	gMainModule, gMainPackage = mainModulePath()
+	gMainModuleRoot           atomic.Pointer[string] // directory of the main module, found by callers in it
+)
+
+// mainModulePath returns paths of the main module and the main package
+func mainModulePath() (module string, pkg string) {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		return info.Main.Path, info.Path
+	}
+	return "", ""
+}
+
+// moduleFile returns the path of file relative to the root of the main module, or package path of the function
+// joined with the file name for other modules
+func moduleFile(function string, file string) string {
+	if root := gMainModuleRoot.Load(); root != nil && strings.HasPrefix(file, *root) {
+		return file[len(*root):]
+	}
+
+	pkg := funcPackage(function)
+	if pkg == "main" && len(gMainPackage) > 0 {
+		pkg = gMainPackage
+	}
+	if len(gMainModule) > 0 && (pkg == gMainModule || strings.HasPrefix(pkg, gMainModule+"/")) {
+		dir := filepath.ToSlash(filepath.Dir(file))
+		if rel := strings.TrimPrefix(pkg, gMainModule); strings.HasSuffix(dir, rel) {
+			root := strings.TrimSuffix(dir, rel) + "/"
+			gMainModuleRoot.Store(&root)
+			return file[len(root):]
+		}
+	}
+
+	return pkg + "/" + path.Base(file)
+}
+
+func prettyCallerModule(caller *runtime.Frame) (function string, file string) {
+	return "", moduleFile(caller.Function, caller.File) + ":" + strconv.Itoa(caller.Line)
+}
+
+func prettyCallerShortFunc(caller *runtime.Frame) (function string, file string) {
+	return shortFunc(caller.Function), caller.File + ":" + strconv.Itoa(caller.Line)
+}
+
+// callerTemplateData is the data of prettycaller templates
+type callerTemplateData struct {
+	Func       string // full function name, e.g. github.com/a/b.(*T).M
+	ShortFunc  string // e.g. b.(*T).M
+	Package    string // e.g. github.com/a/b
+	File       string // full file path
+	ShortFile  string // file path with its last dir, as prettycaller=truncated
+	ModuleFile string // file path relative to the main module, as prettycaller=module
+	Line       int
+}
+
+// newTemplatePrettyfier returns a prettyfier rendering callers by a text/template, e.g. {{.ShortFile}}:{{.Line}} {{.Func}},
+// the result is the file value and the function value is omitted
+func newTemplatePrettyfier(text string) (CallerPrettyfier, error) {
+	tmpl, err := template.New("prettycaller").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse prettycaller template fail:%s", err)
+	}
+
+	return func(caller *runtime.Frame) (function string, file string) {
+		var b bytes.Buffer
+		err := tmpl.Execute(&b, &callerTemplateData{
+			Func:       caller.Function,
+			ShortFunc:  shortFunc(caller.Function),
+			Package:    funcPackage(caller.Function),
+			File:       caller.File,
+			ShortFile:  truncatedPath(caller.File),
+			ModuleFile: moduleFile(caller.Function, caller.File),
+			Line:       caller.Line,
+		})
+		if err != nil {
+			return "", fmt.Sprintf("%s:%d", caller.File, caller.Line)
+		}
+		return "", b.String()
+	}, nil
+}
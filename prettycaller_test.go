@@ -0,0 +1,45 @@
+package qlog
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestLookupCallerPrettyfierUnregistered(t *testing.T) {
+	frame := &runtime.Frame{Function: "main.run", File: "/app/main.go", Line: 12}
+
+	// the logger is configured in init of tests, names not registered are errors
+	if _, err := lookupCallerPrettyfier("test-unregistered"); err == nil {
+		t.Error("unregistered name is accepted after init")
+	}
+
+	var errs []error
+	SetErrorHandler(func(err error) { errs = append(errs, err) })
+	gConfigured.Store(false)
+	defer func() {
+		SetErrorHandler(nil)
+		gConfigured.Store(true)
+	}()
+
+	fn, err := lookupCallerPrettyfier("test-later")
+	if err != nil {
+		t.Fatalf("unregistered name is rejected in init: %s", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if function, file := fn(frame); function != "main.run" || file != "/app/main.go:12" {
+			t.Errorf("got %s %s, want the caller not changed", function, file)
+		}
+	}
+	if len(errs) != 1 {
+		t.Errorf("got %d errors, want 1: %v", len(errs), errs)
+	}
+
+	RegisterCallerPrettyfier("test-later", func(*runtime.Frame) (string, string) { return "", "main.go:12" })
+	if function, file := fn(frame); function != "" || file != "main.go:12" {
+		t.Errorf("got %s %s, want the registered prettyfier", function, file)
+	}
+	if len(errs) != 1 {
+		t.Errorf("got %d errors, want 1: %v", len(errs), errs)
+	}
+}
@@ -0,0 +1,372 @@
+// Package qloggrpc provides grpc interceptors logging calls through qlog
+package qloggrpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kkkbird/qlog"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// field names of grpc calls
+const (
+	FieldPeer       = "peer"
+	FieldCode       = "code"
+	FieldReqSize    = "reqSize"
+	FieldRspSize    = "rspSize"
+	FieldReqPayload = "reqPayload"
+	FieldRspPayload = "rspPayload"
+	FieldRecvMsgs   = "recvMsgs"
+	FieldSentMsgs   = "sentMsgs"
+)
+
+const (
+	defaultMaxPayloadSize = 16 * 1024
+)
+
+// Options are options of interceptors
+type Options struct {
+	// Logger is the base logger, default is qlog default logger with field pkg=grpc
+	Logger *logrus.Entry
+
+	// CodeLevel maps status code to level, default is DefaultCodeLevel
+	CodeLevel func(code codes.Code) logrus.Level
+
+	// Payload logs messages of unary calls as JSON in fields reqPayload and rspPayload
+	Payload bool
+	// MaxPayloadSize is the max bytes of a logged message, longer messages are truncated, default is 16KB
+	MaxPayloadSize int
+}
+
+func (opts Options) withDefaults() Options {
+	if opts.Logger == nil {
+		opts.Logger = logrus.StandardLogger().WithField("pkg", "grpc")
+	}
+	if opts.CodeLevel == nil {
+		opts.CodeLevel = DefaultCodeLevel
+	}
+	if opts.MaxPayloadSize <= 0 {
+		opts.MaxPayloadSize = defaultMaxPayloadSize
+	}
+	return opts
+}
+
+// DefaultCodeLevel maps OK to trace, client errors to warn and server errors to error, like qlog.DefaultStatusLevel
+func DefaultCodeLevel(code codes.Code) logrus.Level {
+	switch code {
+	case codes.OK:
+		return logrus.TraceLevel
+	case codes.Canceled, codes.InvalidArgument, codes.NotFound, codes.AlreadyExists, codes.PermissionDenied,
+		codes.Unauthenticated, codes.ResourceExhausted, codes.FailedPrecondition, codes.Aborted, codes.OutOfRange:
+		return logrus.WarnLevel
+	}
+	return logrus.ErrorLevel
+}
+
+func msgSize(m interface{}) int {
+	if pm, ok := m.(proto.Message); ok {
+		return proto.Size(pm)
+	}
+	return 0
+}
+
+// payload returns the message as JSON, or a truncated string if it is longer than MaxPayloadSize
+func (opts *Options) payload(m interface{}) interface{} {
+	pm, ok := m.(proto.Message)
+	if !ok {
+		return fmt.Sprint(m)
+	}
+
+	b, err := protojson.Marshal(pm)
+	if err != nil {
+		return fmt.Sprintf("marshal fail:%s", err)
+	}
+	if len(b) > opts.MaxPayloadSize {
+		return string(b[:opts.MaxPayloadSize]) + "..."
+	}
+
+	var v interface{}
+	if err = json.Unmarshal(b, &v); err != nil {
+		return string(b)
+	}
+	return v
+}
+
+func peerAddr(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return p.Addr.String()
+	}
+	return ""
+}
+
+// incoming returns fields of request id and traceparent in incoming metadata, and ctx carrying the request-scoped
+// entry of logger with the fields
+func incoming(ctx context.Context, logger *logrus.Entry) (context.Context, logrus.Fields) {
+	header := make(http.Header, 2)
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for _, key := range []string{qlog.HeaderRequestID, qlog.HeaderTraceparent} {
+			if values := md.Get(key); len(values) > 0 {
+				header.Set(key, values[0])
+			}
+		}
+	}
+
+	fields := qlog.RequestFields(header)
+	return qlog.NewContext(ctx, logger.WithFields(fields)), fields
+}
+
+// outgoing adds request id and traceparent of the request-scoped entry in ctx to outgoing metadata
+func outgoing(ctx context.Context) context.Context {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	for key, values := range qlog.PropagationHeader(ctx) {
+		if key = strings.ToLower(key); len(md.Get(key)) == 0 {
+			ctx = metadata.AppendToOutgoingContext(ctx, key, values[0])
+		}
+	}
+	return ctx
+}
+
+func latencyMS(start time.Time) int {
+	return int(math.Ceil(float64(time.Since(start).Nanoseconds()) / 1000000.0))
+}
+
+// log logs a finished call
+func (opts *Options) log(entry *logrus.Entry, method string, err error, fields logrus.Fields) {
+	code := status.Code(err)
+	level := opts.CodeLevel(code)
+
+	fields[FieldCode] = code.String()
+	entry = entry.WithFields(fields)
+	if err != nil {
+		entry = entry.WithError(err)
+	}
+
+	entry.Log(level, fmt.Sprintf("%s %s (%dms)", code, method, fields[qlog.FieldLatency]))
+}
+
+// UnaryServerInterceptor logs unary calls, and injects a request-scoped entry into the context of handlers
+func UnaryServerInterceptor(opts Options) grpc.UnaryServerInterceptor {
+	opts = opts.withDefaults()
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+
+		ctx, reqFields := incoming(ctx, opts.Logger)
+		grpc.SetHeader(ctx, metadata.Pairs(qlog.HeaderRequestID, reqFields[qlog.FieldRequestID].(string)))
+
+		rsp, err := handler(ctx, req)
+
+		entry := opts.Logger.WithFields(reqFields)
+		fields := logrus.Fields{
+			qlog.FieldMethod:  info.FullMethod,
+			qlog.FieldLatency: latencyMS(start),
+			FieldPeer:         peerAddr(ctx),
+			FieldReqSize:      msgSize(req),
+		}
+		if err == nil {
+			fields[FieldRspSize] = msgSize(rsp)
+		}
+		if opts.Payload && entry.Logger.IsLevelEnabled(opts.CodeLevel(status.Code(err))) {
+			fields[FieldReqPayload] = opts.payload(req)
+			if err == nil {
+				fields[FieldRspPayload] = opts.payload(rsp)
+			}
+		}
+
+		opts.log(entry, info.FullMethod, err, fields)
+		return rsp, err
+	}
+}
+
+// msgCounts counts messages of a stream, SendMsg and RecvMsg may be called by different goroutines
+type msgCounts struct {
+	recvMsgs, sentMsgs atomic.Int64
+	recvSize, sentSize atomic.Int64
+}
+
+func (c *msgCounts) recv(m interface{}) {
+	c.recvMsgs.Add(1)
+	c.recvSize.Add(int64(msgSize(m)))
+}
+
+func (c *msgCounts) sent(m interface{}) {
+	c.sentMsgs.Add(1)
+	c.sentSize.Add(int64(msgSize(m)))
+}
+
+// addFields adds counts to fields, sizes of received and sent messages are request and response sizes of servers,
+// and response and request sizes of clients
+func (c *msgCounts) addFields(fields logrus.Fields, server bool) {
+	reqSize, rspSize := c.sentSize.Load(), c.recvSize.Load()
+	if server {
+		reqSize, rspSize = rspSize, reqSize
+	}
+
+	fields[FieldReqSize] = reqSize
+	fields[FieldRspSize] = rspSize
+	fields[FieldRecvMsgs] = c.recvMsgs.Load()
+	fields[FieldSentMsgs] = c.sentMsgs.Load()
+}
+
+// serverStream counts messages of a server stream and carries the request-scoped entry in its context
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+	msgCounts
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *serverStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.recv(m)
+	}
+	return err
+}
+
+func (s *serverStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.sent(m)
+	}
+	return err
+}
+
+// StreamServerInterceptor logs streaming calls when they finish, and injects a request-scoped entry into the stream context
+func StreamServerInterceptor(opts Options) grpc.StreamServerInterceptor {
+	opts = opts.withDefaults()
+
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+
+		ctx, reqFields := incoming(ss.Context(), opts.Logger)
+		ss.SetHeader(metadata.Pairs(qlog.HeaderRequestID, reqFields[qlog.FieldRequestID].(string)))
+
+		s := &serverStream{ServerStream: ss, ctx: ctx}
+		err := handler(srv, s)
+
+		fields := logrus.Fields{
+			qlog.FieldMethod:  info.FullMethod,
+			qlog.FieldLatency: latencyMS(start),
+			FieldPeer:         peerAddr(ctx),
+		}
+		s.addFields(fields, true)
+
+		opts.log(opts.Logger.WithFields(reqFields), info.FullMethod, err, fields)
+		return err
+	}
+}
+
+// UnaryClientInterceptor logs unary calls, request id and traceparent of the request-scoped entry in ctx are propagated
+func UnaryClientInterceptor(opts Options) grpc.UnaryClientInterceptor {
+	opts = opts.withDefaults()
+
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, callOpts ...grpc.CallOption) error {
+		start := time.Now()
+
+		err := invoker(outgoing(ctx), method, req, reply, cc, callOpts...)
+
+		entry := opts.Logger.WithFields(qlog.FromContext(ctx).Data).WithContext(ctx)
+		fields := logrus.Fields{
+			qlog.FieldMethod:  method,
+			qlog.FieldLatency: latencyMS(start),
+			FieldPeer:         cc.Target(),
+			FieldReqSize:      msgSize(req),
+		}
+		if err == nil {
+			fields[FieldRspSize] = msgSize(reply)
+		}
+		if opts.Payload && entry.Logger.IsLevelEnabled(opts.CodeLevel(status.Code(err))) {
+			fields[FieldReqPayload] = opts.payload(req)
+			if err == nil {
+				fields[FieldRspPayload] = opts.payload(reply)
+			}
+		}
+
+		opts.log(entry, method, err, fields)
+		return err
+	}
+}
+
+// clientStream counts messages of a client stream, and logs the call when it finishes
+type clientStream struct {
+	grpc.ClientStream
+	once   sync.Once
+	finish func(err error, s *clientStream)
+	msgCounts
+}
+
+func (s *clientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	if err == nil {
+		s.sent(m)
+	} else if err != io.EOF {
+		s.once.Do(func() { s.finish(err, s) })
+	}
+	return err
+}
+
+func (s *clientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err == nil {
+		s.recv(m)
+		return nil
+	}
+
+	finishErr := err
+	if err == io.EOF {
+		finishErr = nil
+	}
+	s.once.Do(func() { s.finish(finishErr, s) })
+	return err
+}
+
+// StreamClientInterceptor logs streaming calls when RecvMsg returns an error or io.EOF,
+// request id and traceparent of the request-scoped entry in ctx are propagated
+func StreamClientInterceptor(opts Options) grpc.StreamClientInterceptor {
+	opts = opts.withDefaults()
+
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, callOpts ...grpc.CallOption) (grpc.ClientStream, error) {
+		start := time.Now()
+		entry := opts.Logger.WithFields(qlog.FromContext(ctx).Data).WithContext(ctx)
+
+		finish := func(err error, s *clientStream) {
+			fields := logrus.Fields{
+				qlog.FieldMethod:  method,
+				qlog.FieldLatency: latencyMS(start),
+				FieldPeer:         cc.Target(),
+			}
+			if s != nil {
+				s.addFields(fields, false)
+			}
+			opts.log(entry, method, err, fields)
+		}
+
+		cs, err := streamer(outgoing(ctx), desc, cc, method, callOpts...)
+		if err != nil {
+			finish(err, nil)
+			return nil, err
+		}
+
+		return &clientStream{ClientStream: cs, finish: finish}, nil
+	}
+}
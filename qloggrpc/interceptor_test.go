@@ -0,0 +1,231 @@
+package qloggrpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/kkkbird/qlog"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const (
+	methodCheck = "/grpc.health.v1.Health/Check"
+	methodChat  = "/qloggrpc.test.Echo/Chat"
+)
+
+// echoDesc is a bidi streaming service echoing StringValue messages
+var echoDesc = grpc.ServiceDesc{
+	ServiceName: "qloggrpc.test.Echo",
+	HandlerType: (*interface{})(nil),
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "Chat",
+		ServerStreams: true,
+		ClientStreams: true,
+		Handler: func(srv interface{}, ss grpc.ServerStream) error {
+			for {
+				m := new(wrapperspb.StringValue)
+				if err := ss.RecvMsg(m); err == io.EOF {
+					return nil
+				} else if err != nil {
+					return err
+				}
+				if err := ss.SendMsg(m); err != nil {
+					return err
+				}
+			}
+		},
+	}},
+}
+
+// setup starts a bufconn server and returns a client conn, interceptors of both sides log to hook
+func setup(t *testing.T) (*grpc.ClientConn, *logtest.Hook) {
+	logger, hook := logtest.NewNullLogger()
+	logger.SetLevel(logrus.TraceLevel)
+
+	opts := Options{Logger: logrus.NewEntry(logger), Payload: true}
+
+	lis := bufconn.Listen(1024 * 1024)
+	s := grpc.NewServer(
+		grpc.UnaryInterceptor(UnaryServerInterceptor(opts)),
+		grpc.StreamInterceptor(StreamServerInterceptor(opts)),
+	)
+	grpc_health_v1.RegisterHealthServer(s, health.NewServer())
+	s.RegisterService(&echoDesc, struct{}{})
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	cc, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor(opts)),
+		grpc.WithStreamInterceptor(StreamClientInterceptor(opts)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cc.Close() })
+
+	return cc, hook
+}
+
+// waitEntries waits for n entries, the server logs a call after the client gets its status
+func waitEntries(t *testing.T, hook *logtest.Hook, n int) []logrus.Entry {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		entries := hook.AllEntries()
+		if len(entries) >= n {
+			if len(entries) > n {
+				t.Fatalf("got %d entries, want %d", len(entries), n)
+			}
+			out := make([]logrus.Entry, n)
+			for i, e := range entries {
+				out[i] = *e
+			}
+			return out
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d entries, want %d", len(entries), n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// split returns the entries of the server and the client, the client has no peer address of bufconn
+func split(t *testing.T, entries []logrus.Entry) (server logrus.Entry, client logrus.Entry) {
+	for _, e := range entries {
+		if e.Data[FieldPeer] == "passthrough:///bufnet" {
+			client = e
+		} else {
+			server = e
+		}
+	}
+	if server.Data == nil || client.Data == nil {
+		t.Fatalf("entries of server and client are not found: %v", entries)
+	}
+	return server, client
+}
+
+func checkField(t *testing.T, e logrus.Entry, key string, want interface{}) {
+	t.Helper()
+	if got := e.Data[key]; got != want {
+		t.Errorf("%s: got %v(%T), want %v(%T)", key, got, got, want, want)
+	}
+}
+
+func TestUnaryInterceptors(t *testing.T) {
+	cc, hook := setup(t)
+
+	ctx := qlog.NewContext(context.Background(), logrus.WithField(qlog.FieldRequestID, "req-1"))
+	req := &grpc_health_v1.HealthCheckRequest{}
+	if _, err := grpc_health_v1.NewHealthClient(cc).Check(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+
+	server, client := split(t, waitEntries(t, hook, 2))
+	for _, e := range []logrus.Entry{server, client} {
+		if e.Level != logrus.TraceLevel {
+			t.Errorf("level: got %s, want trace", e.Level)
+		}
+		checkField(t, e, qlog.FieldMethod, methodCheck)
+		checkField(t, e, qlog.FieldRequestID, "req-1")
+		checkField(t, e, FieldCode, codes.OK.String())
+		checkField(t, e, FieldReqSize, 0)
+		if _, ok := e.Data[FieldRspPayload]; !ok {
+			t.Errorf("%s is not logged", FieldRspPayload)
+		}
+	}
+}
+
+func TestUnaryInterceptorsError(t *testing.T) {
+	cc, hook := setup(t)
+
+	req := &grpc_health_v1.HealthCheckRequest{Service: "unknown"}
+	_, err := grpc_health_v1.NewHealthClient(cc).Check(context.Background(), req)
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("got %v, want NotFound", err)
+	}
+
+	server, client := split(t, waitEntries(t, hook, 2))
+	for _, e := range []logrus.Entry{server, client} {
+		if e.Level != logrus.WarnLevel {
+			t.Errorf("level: got %s, want warn", e.Level)
+		}
+		checkField(t, e, FieldCode, codes.NotFound.String())
+		checkField(t, e, FieldReqSize, msgSize(req))
+		if _, ok := e.Data[FieldRspSize]; ok {
+			t.Errorf("%s is logged for an error", FieldRspSize)
+		}
+	}
+
+	if id, ok := server.Data[qlog.FieldRequestID].(string); !ok || len(id) == 0 {
+		t.Errorf("request id is not generated by server")
+	}
+}
+
+// TestStreamInterceptors sends and receives by different goroutines, it is meant to run with -race
+func TestStreamInterceptors(t *testing.T) {
+	const n = 10
+
+	cc, hook := setup(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cs, err := cc.NewStream(ctx, &echoDesc.Streams[0], methodChat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := wrapperspb.String("hello")
+	sendErr := make(chan error, 1)
+	go func() {
+		for i := 0; i < n; i++ {
+			if err := cs.SendMsg(msg); err != nil {
+				sendErr <- err
+				return
+			}
+		}
+		sendErr <- cs.CloseSend()
+	}()
+
+	recv := 0
+	for {
+		if err = cs.RecvMsg(new(wrapperspb.StringValue)); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		recv++
+	}
+	if err = <-sendErr; err != nil {
+		t.Fatal(err)
+	}
+	if recv != n {
+		t.Fatalf("got %d messages, want %d", recv, n)
+	}
+
+	size := int64(n * msgSize(msg))
+	server, client := split(t, waitEntries(t, hook, 2))
+	for _, e := range []logrus.Entry{server, client} {
+		checkField(t, e, qlog.FieldMethod, methodChat)
+		checkField(t, e, FieldCode, codes.OK.String())
+		checkField(t, e, FieldRecvMsgs, int64(n))
+		checkField(t, e, FieldSentMsgs, int64(n))
+		checkField(t, e, FieldReqSize, size)
+		checkField(t, e, FieldRspSize, size)
+	}
+}
@@ -0,0 +1,6 @@
+//go:build race
+
+package qlog
+
+// raceEnabled is true if tests run with the race detector
+const raceEnabled = true
@@ -0,0 +1,197 @@
+package qlog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// field names of recovered panics
+const (
+	FieldPanic = "panic"
+	FieldStack = "stack"
+)
+
+const (
+	maxStackDepth = 64
+)
+
+// StackFrame is a frame of a stack trace
+type StackFrame struct {
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+}
+
+func (f StackFrame) String() string {
+	return fmt.Sprintf("%s(%s:%d)", f.Function, f.File, f.Line)
+}
+
+// panicStack returns frames of a panicking goroutine from the function which panicked, runtime frames of the panic
+// (e.g. runtime.sigpanic) are skipped, it must be called in the deferred function which recovers the panic
+func panicStack() Stack {
+	pcs := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	stack := make(Stack, 0, n)
+	for {
+		frame, more := frames.Next()
+		if frame.Function == "runtime.gopanic" {
+			stack = stack[:0]
+		} else if len(stack) > 0 || !strings.HasPrefix(frame.Function, "runtime.") {
+			stack = append(stack, StackFrame{Function: frame.Function, File: frame.File, Line: frame.Line})
+		}
+		if !more {
+			break
+		}
+	}
+
+	return stack
+}
+
+// brokenPipe checks if a panic is caused by a closed client connection, the response can't be written
+func brokenPipe(recovered interface{}) bool {
+	err, ok := recovered.(error)
+	if !ok {
+		return false
+	}
+
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) {
+		return false
+	}
+
+	var syscallErr *os.SyscallError
+	if errors.As(opErr, &syscallErr) {
+		msg := strings.ToLower(syscallErr.Error())
+		return strings.Contains(msg, "broken pipe") || strings.Contains(msg, "connection reset by peer")
+	}
+	return false
+}
+
+// DefaultRecoveryResponse writes status 500 with its status text
+func DefaultRecoveryResponse(w http.ResponseWriter, r *http.Request, recovered interface{}) {
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
+
+// RecoveryConfig is the config of GinRecoveryWithConfig and HTTPRecovery
+type RecoveryConfig struct {
+	// Logger is the base logger, default is qlog default logger with field pkg=gin or pkg=http
+	Logger *logrus.Entry
+
+	// Response writes the response of a recovered panic, default is DefaultRecoveryResponse,
+	// it is not called if the response has been written or the client connection is broken
+	Response func(w http.ResponseWriter, r *http.Request, recovered interface{})
+}
+
+func (conf RecoveryConfig) withDefaults(pkg string) RecoveryConfig {
+	if conf.Logger == nil {
+		conf.Logger = logrus.StandardLogger().WithField("pkg", pkg)
+	}
+	if conf.Response == nil {
+		conf.Response = DefaultRecoveryResponse
+	}
+	return conf
+}
+
+// logPanic logs a recovered panic at error level with its stack, the caller is the function which panicked
+func logPanic(ctx context.Context, entry *logrus.Entry, recovered interface{}, stack Stack, fields logrus.Fields) {
+	if len(stack) > 0 {
+		f := stack[0]
+		ctx = withCaller(ctx, &runtime.Frame{Function: f.Function, File: f.File, Line: f.Line})
+	}
+
+	fields[FieldPanic] = fmt.Sprint(recovered)
+	fields[FieldStack] = stack
+
+	entry.WithContext(ctx).WithFields(fields).Errorf("panic recovered: %v", recovered)
+}
+
+// GinRecovery recovers panics of handlers and logs them with stack through qlog, it writes status 500
+func GinRecovery(logger ...*logrus.Entry) gin.HandlerFunc {
+	return GinRecoveryWithConfig(RecoveryConfig{Logger: getGinLogger(logger...)})
+}
+
+// GinRecoveryWithConfig returns a GinRecovery with config
+func GinRecoveryWithConfig(conf RecoveryConfig) gin.HandlerFunc {
+	conf = conf.withDefaults("gin")
+
+	return func(c *gin.Context) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+
+			stack := panicStack()
+			logPanic(c.Request.Context(), conf.Logger.WithFields(ginRequestFields(c)), recovered, stack, logrus.Fields{
+				FieldMethod:   c.Request.Method,
+				FieldPath:     c.Request.URL.Path,
+				FieldClientIP: c.ClientIP(),
+			})
+
+			if brokenPipe(recovered) {
+				c.Error(recovered.(error))
+				c.Abort()
+				return
+			}
+
+			if !c.Writer.Written() {
+				conf.Response(c.Writer, c.Request, recovered)
+			}
+			c.Abort()
+		}()
+
+		c.Next()
+	}
+}
+
+// HTTPRecovery recovers panics of next and logs them with stack through qlog,
+// fields of the request-scoped entry set by HTTPMiddleware are logged if it wraps HTTPRecovery
+func HTTPRecovery(next http.Handler, conf RecoveryConfig) http.Handler {
+	conf = conf.withDefaults("http")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := &responseWriter{ResponseWriter: w}
+
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+
+			entry := conf.Logger
+			if ctxEntry, ok := r.Context().Value(ctxKeyEntry).(*logrus.Entry); ok {
+				entry = entry.WithFields(ctxEntry.Data)
+			}
+
+			stack := panicStack()
+			logPanic(r.Context(), entry, recovered, stack, logrus.Fields{
+				FieldMethod:   r.Method,
+				FieldPath:     r.URL.Path,
+				FieldClientIP: remoteIP(r),
+			})
+
+			if !brokenPipe(recovered) && rw.status == 0 && !rw.hijacked {
+				conf.Response(rw, r, recovered)
+			}
+		}()
+
+		next.ServeHTTP(wrapResponseWriter(rw), r)
+	})
+}
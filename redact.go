@@ -0,0 +1,295 @@
+package qlog
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	keyRedactFields   = "logger.redact.fields"
+	keyRedactValues   = "logger.redact.values"
+	keyRedactBuiltins = "logger.redact.builtins"
+	keyRedactMode     = "logger.redact.mode"
+	keyRedactMask     = "logger.redact.mask"
+
+	redactModeMask = "mask"
+	redactModeHash = "hash"
+)
+
+// valueRule redacts matches of re in string values, only the first group is redacted if re has a group
+type valueRule struct {
+	re    *regexp.Regexp
+	check func(match string) bool // optional check of a match, e.g. luhn for credit card numbers
+}
+
+var gRedactBuiltins = map[string]valueRule{
+	"bearer":     {re: regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9\-._~+/]+=*)`)},
+	"email":      {re: regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)},
+	"creditcard": {re: regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`), check: cardNumberValid},
+}
+
+// cardIIN is a range of issuer prefixes of a card network and lengths of its card numbers
+type cardIIN struct {
+	digits         int // length of the prefix
+	lo, hi         int // prefix range
+	minLen, maxLen int
+}
+
+// prefixes of major card networks, numbers of other networks are not redacted to avoid masking ids and phone numbers
+var gCardIINs = []cardIIN{
+	{1, 4, 4, 13, 19},       // visa
+	{2, 51, 55, 16, 16},     // mastercard
+	{4, 2221, 2720, 16, 16}, // mastercard
+	{2, 34, 34, 15, 15},     // amex
+	{2, 37, 37, 15, 15},     // amex
+	{4, 6011, 6011, 16, 19}, // discover
+	{3, 644, 649, 16, 19},   // discover
+	{2, 65, 65, 16, 19},     // discover
+	{4, 3528, 3589, 16, 19}, // jcb
+	{3, 300, 305, 14, 19},   // diners club
+	{2, 36, 36, 14, 19},     // diners club
+	{2, 38, 39, 16, 19},     // diners club
+	{2, 62, 62, 16, 19},     // unionpay
+}
+
+// cardNumberValid checks the issuer prefix, the length and the luhn checksum of a card number,
+// spaces and dashes are ignored
+func cardNumberValid(number string) bool {
+	digits := make([]byte, 0, len(number))
+	for i := 0; i < len(number); i++ {
+		if c := number[i]; c >= '0' && c <= '9' {
+			digits = append(digits, c)
+		}
+	}
+
+	for _, iin := range gCardIINs {
+		if len(digits) < iin.minLen || len(digits) > iin.maxLen {
+			continue
+		}
+		prefix, _ := strconv.Atoi(string(digits[:iin.digits]))
+		if prefix >= iin.lo && prefix <= iin.hi {
+			return luhnValid(number)
+		}
+	}
+	return false
+}
+
+// luhnValid checks the luhn checksum of a card number, spaces and dashes are ignored
+func luhnValid(number string) bool {
+	sum, n := 0, 0
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n > 0 && sum%10 == 0
+}
+
+// redactor masks or hashes sensitive fields and values in entry data and message before formatters run
+type redactor struct {
+	fieldRe *regexp.Regexp // matches sensitive field names
+	jsonRe  *regexp.Regexp // matches values of sensitive keys in json text, e.g. "password":"xxx"
+	formRe  *regexp.Regexp // matches values of sensitive keys in form or query text, e.g. password=xxx
+	values  []valueRule
+	hash    bool
+	mask    string
+}
+
+// globsToRegexp converts case-insensitive field name globs to a regexp alternation, '*' matches any of chars
+func globsToRegexp(globs []string, chars string) string {
+	alts := make([]string, 0, len(globs))
+	for _, g := range globs {
+		alts = append(alts, strings.ReplaceAll(regexp.QuoteMeta(g), `\*`, chars+"*"))
+	}
+	return "(?i:" + strings.Join(alts, "|") + ")"
+}
+
+func newRedactor(fields []string, values []string, builtins []string, mode string, mask string) (*redactor, error) {
+	r := &redactor{mask: mask}
+
+	switch mode {
+	case redactModeMask, "":
+	case redactModeHash:
+		r.hash = true
+	default:
+		return nil, fmt.Errorf("%s: unsupported mode(%s)", keyRedactMode, mode)
+	}
+
+	if len(fields) > 0 {
+		r.fieldRe = regexp.MustCompile("^" + globsToRegexp(fields, ".") + "$")
+		r.jsonRe = regexp.MustCompile(`"` + globsToRegexp(fields, `[^"]`) + `"\s*:\s*("(?:[^"\\]|\\.)*"|[^,}\]\s]+)`)
+		r.formRe = regexp.MustCompile(`(?:^|[?&;\s])` + globsToRegexp(fields, `[^=&\s]`) + `=([^&\s]*)`)
+	}
+
+	for _, name := range builtins {
+		rule, ok := gRedactBuiltins[name]
+		if !ok {
+			return nil, fmt.Errorf("%s: unknown builtin(%s)", keyRedactBuiltins, name)
+		}
+		r.values = append(r.values, rule)
+	}
+
+	for _, expr := range values {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", keyRedactValues, err)
+		}
+		r.values = append(r.values, valueRule{re: re})
+	}
+
+	return r, nil
+}
+
+// replace returns the replacement of a sensitive value
+func (r *redactor) replace(value string) string {
+	if r.hash {
+		sum := sha256.Sum256([]byte(value))
+		return "sha256:" + hex.EncodeToString(sum[:8])
+	}
+	return r.mask
+}
+
+// replaceGroup replaces the first group of every match of re in s, or the whole match if re has no group
+func (r *redactor) replaceGroup(re *regexp.Regexp, s string, check func(string) bool, quoted bool) string {
+	matches := re.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return s
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if len(m) > 2 && m[2] >= 0 {
+			start, end = m[2], m[3]
+		}
+
+		value := s[start:end]
+		if check != nil && !check(value) {
+			continue
+		}
+
+		b.WriteString(s[last:start])
+		if quoted {
+			b.WriteString(`"` + r.replace(strings.Trim(value, `"`)) + `"`)
+		} else {
+			b.WriteString(r.replace(value))
+		}
+		last = end
+	}
+	b.WriteString(s[last:])
+
+	return b.String()
+}
+
+func (r *redactor) redactString(s string) string {
+	if r.jsonRe != nil {
+		s = r.replaceGroup(r.jsonRe, s, nil, true)
+		s = r.replaceGroup(r.formRe, s, nil, false)
+	}
+
+	for _, rule := range r.values {
+		s = r.replaceGroup(rule.re, s, rule.check, false)
+	}
+
+	return s
+}
+
+// redactValue returns the redacted value of a field, maps and slices are copied before changed
+func (r *redactor) redactValue(key string, value interface{}) interface{} {
+	if len(key) > 0 && r.fieldRe != nil && r.fieldRe.MatchString(key) {
+		return r.replace(fmt.Sprint(value))
+	}
+
+	switch val := value.(type) {
+	case string:
+		return r.redactString(val)
+	case []byte:
+		if s := r.redactString(string(val)); s != string(val) {
+			return s
+		}
+	case error:
+		if s := r.redactString(val.Error()); s != val.Error() {
+			return s
+		}
+	case logrus.Fields:
+		return logrus.Fields(r.redactMap(val))
+	case map[string]interface{}:
+		return r.redactMap(val)
+	case []interface{}:
+		values := make([]interface{}, len(val))
+		for i, v := range val {
+			values[i] = r.redactValue("", v)
+		}
+		return values
+	case []string:
+		values := make([]string, len(val))
+		for i, v := range val {
+			values[i] = r.redactString(v)
+		}
+		return values
+	case ErrorChain:
+		values := make(ErrorChain, len(val))
+		for i, v := range val {
+			values[i] = r.redactString(v)
+		}
+		return values
+	}
+
+	return value
+}
+
+func (r *redactor) redactMap(m map[string]interface{}) map[string]interface{} {
+	values := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		values[k] = r.redactValue(k, v)
+	}
+	return values
+}
+
+// redact changes the entry in place, e.Data is a copy made by logrus for each entry
+func (r *redactor) redact(e *logrus.Entry) {
+	e.Message = r.redactString(e.Message)
+
+	for k, v := range e.Data {
+		e.Data[k] = r.redactValue(k, v)
+	}
+}
+
+// getRedactor returns nil if redaction is not configured
+func getRedactor() (*redactor, error) {
+	fields := v.GetStringSlice(keyRedactFields)
+	values := v.GetStringSlice(keyRedactValues)
+	builtins := v.GetStringSlice(keyRedactBuiltins)
+
+	if len(fields) == 0 && len(values) == 0 && len(builtins) == 0 {
+		return nil, nil
+	}
+
+	return newRedactor(fields, values, builtins, v.GetString(keyRedactMode), v.GetString(keyRedactMask))
+}
+
+func checkRedact(key string) error {
+	_, err := getRedactor()
+	return err
+}
+
+var _InitRedactor = func() interface{} {
+	registerConfigKey("logger.redact.", checkRedact)
+	return nil
+}()
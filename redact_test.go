@@ -0,0 +1,150 @@
+package qlog
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestCardNumberValid(t *testing.T) {
+	tests := []struct {
+		number string
+		want   bool
+	}{
+		{"4111111111111111", true},    // visa
+		{"4111 1111 1111 1111", true}, // visa with spaces
+		{"4111-1111-1111-1111", true}, // visa with dashes
+		{"5555555555554444", true},    // mastercard
+		{"378282246310005", true},     // amex
+		{"6011111111111117", true},    // discover
+		{"4111111111111112", false},   // bad luhn checksum
+		{"1234567812345670", false},   // luhn ok, no issuer prefix
+		{"1000000000000008", false},   // id, luhn ok, no issuer prefix
+		{"8613800138002", false},      // phone number, luhn ok, no issuer prefix
+		{"37828224631000", false},     // amex prefix, wrong length
+	}
+
+	for _, tt := range tests {
+		if got := cardNumberValid(tt.number); got != tt.want {
+			t.Errorf("cardNumberValid(%q): got %v, want %v", tt.number, got, tt.want)
+		}
+	}
+}
+
+func TestRedactor(t *testing.T) {
+	tests := []struct {
+		name     string
+		fields   []string
+		values   []string
+		builtins []string
+		mode     string
+		data     logrus.Fields
+		msg      string
+		wantData logrus.Fields
+		wantMsg  string
+	}{
+		{
+			name:     "field globs",
+			fields:   []string{"password", "*token*"},
+			data:     logrus.Fields{"Password": "secret", "accessToken": "t1", "token": "t2", "user": "bob", "passwords": "p"},
+			wantData: logrus.Fields{"Password": "***", "accessToken": "***", "token": "***", "user": "bob", "passwords": "p"},
+		},
+		{
+			name:     "nested fields",
+			fields:   []string{"password"},
+			data:     logrus.Fields{"req": map[string]interface{}{"password": 123, "user": "bob"}},
+			wantData: logrus.Fields{"req": map[string]interface{}{"password": "***", "user": "bob"}},
+		},
+		// unquoted values are replaced by quoted strings, so that the json is still valid
+		{
+			name:    "json body",
+			fields:  []string{"password", "*secret"},
+			msg:     `body {"user":"bob","password" : "p\"w", "clientSecret":42,"passwordHint":"h"}`,
+			wantMsg: `body {"user":"bob","password" : "***", "clientSecret":"***","passwordHint":"h"}`,
+		},
+		{
+			name:    "form body",
+			fields:  []string{"password", "*token"},
+			msg:     "GET /login?user=bob&password=p1&access_token=t1 password=p2 mypassword=x",
+			wantMsg: "GET /login?user=bob&password=***&access_token=*** password=*** mypassword=x",
+		},
+		{
+			name:     "bearer",
+			builtins: []string{"bearer"},
+			data:     logrus.Fields{"auth": "Bearer eyJhbGciOi.J9.x-y_z=="},
+			wantData: logrus.Fields{"auth": "Bearer ***"},
+		},
+		{
+			name:     "email",
+			builtins: []string{"email"},
+			msg:      "sent to bob.smith+x@example.co.uk, cc alice@example.com",
+			wantMsg:  "sent to ***, cc ***",
+			data:     logrus.Fields{"error": errors.New("no user alice@example.com")},
+			wantData: logrus.Fields{"error": "no user ***"},
+		},
+		{
+			name:     "creditcard",
+			builtins: []string{"creditcard"},
+			msg:      "paid by 4111 1111 1111 1111 and 5555-5555-5555-4444",
+			wantMsg:  "paid by *** and ***",
+		},
+		{
+			name:     "creditcard negatives",
+			builtins: []string{"creditcard"},
+			msg:      "order 1234567812345670 phone 8613800138002 card 4111111111111112",
+			wantMsg:  "order 1234567812345670 phone 8613800138002 card 4111111111111112",
+			data:     logrus.Fields{"id": 1000000000000008, "orderId": "1000000000000008"},
+			wantData: logrus.Fields{"id": 1000000000000008, "orderId": "1000000000000008"},
+		},
+		{
+			name:    "value regexp with group",
+			values:  []string{`ssn:(\d{3}-\d{2}-\d{4})`},
+			msg:     "user ssn:123-45-6789",
+			wantMsg: "user ssn:***",
+		},
+		{
+			name:     "hash mode",
+			fields:   []string{"password"},
+			builtins: []string{"creditcard"},
+			mode:     redactModeHash,
+			msg:      `card 4111111111111111 {"password":"secret"}`,
+			wantMsg:  `card sha256:9bbef19476623ca5 {"password":"sha256:2bb80d537b1da3e3"}`,
+			data:     logrus.Fields{"password": "secret"},
+			wantData: logrus.Fields{"password": "sha256:2bb80d537b1da3e3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := newRedactor(tt.fields, tt.values, tt.builtins, tt.mode, "***")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			e := logrus.NewEntry(logrus.New()).WithFields(tt.data)
+			e.Message = tt.msg
+			r.redact(e)
+
+			if e.Message != tt.wantMsg {
+				t.Errorf("message: got %q, want %q", e.Message, tt.wantMsg)
+			}
+			if got, want := fmt.Sprint(e.Data), fmt.Sprint(tt.wantData); got != want {
+				t.Errorf("data: got %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestNewRedactorErrors(t *testing.T) {
+	if _, err := newRedactor(nil, nil, nil, "encrypt", "***"); err == nil {
+		t.Error("unsupported mode is accepted")
+	}
+	if _, err := newRedactor(nil, nil, []string{"phone"}, "", "***"); err == nil {
+		t.Error("unknown builtin is accepted")
+	}
+	if _, err := newRedactor(nil, []string{"("}, nil, "", "***"); err == nil {
+		t.Error("bad regexp is accepted")
+	}
+}
@@ -0,0 +1,145 @@
+package qlog
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// HeaderRequestID is the header of request id, it is echoed in response
+	HeaderRequestID = "X-Request-ID"
+	// HeaderTraceparent is the W3C trace context header
+	HeaderTraceparent = "traceparent"
+
+	// field names of the request-scoped entry
+	FieldRequestID  = "requestId"
+	FieldTraceID    = "traceId"
+	FieldSpanID     = "spanId"
+	FieldTraceFlags = "traceFlags"
+
+	maxRequestIDLength = 128
+
+	// trace flags of outbound requests if the request-scoped entry has no valid flags, 01 is sampled
+	defaultTraceFlags = "01"
+)
+
+// newRequestID generates a random uuid v4
+func newRequestID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "unknown"
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// newSpanID generates a random W3C span id
+func newSpanID() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// isLowerHex checks if s is lowercase hex, W3C trace context ignores headers with uppercase hex
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func validTraceFlags(flags string) bool {
+	return len(flags) == 2 && isLowerHex(flags)
+}
+
+func isZeroHex(s string) bool {
+	return strings.Trim(s, "0") == ""
+}
+
+// parseTraceparent parses a W3C traceparent header, e.g. 00-4bf92f3577b34da6a3ce929b0e0e4736-00f067aa0ba902b7-01
+func parseTraceparent(header string) (traceID string, spanID string, flags string, ok bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 {
+		return "", "", "", false
+	}
+
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+	if len(version) != 2 || !isLowerHex(version) || version == "ff" || (version == "00" && len(parts) != 4) {
+		return "", "", "", false
+	}
+	if len(traceID) != 32 || !isLowerHex(traceID) || isZeroHex(traceID) {
+		return "", "", "", false
+	}
+	if len(spanID) != 16 || !isLowerHex(spanID) || isZeroHex(spanID) {
+		return "", "", "", false
+	}
+	if !validTraceFlags(flags) {
+		return "", "", "", false
+	}
+
+	return traceID, spanID, flags, true
+}
+
+// requestID returns the request id in header, or a new one if it is not set or invalid
+func requestID(header http.Header) string {
+	id := header.Get(HeaderRequestID)
+	if len(id) == 0 || len(id) > maxRequestIDLength || strings.ContainsAny(id, "\r\n") {
+		return newRequestID()
+	}
+	return id
+}
+
+// requestFields returns request id and trace context fields of a request
+func requestFields(header http.Header, rid string) logrus.Fields {
+	fields := logrus.Fields{FieldRequestID: rid}
+
+	if traceID, spanID, flags, ok := parseTraceparent(header.Get(HeaderTraceparent)); ok {
+		fields[FieldTraceID] = traceID
+		fields[FieldSpanID] = spanID
+		fields[FieldTraceFlags] = flags
+	}
+
+	return fields
+}
+
+// RequestFields returns request id and trace context fields by headers of an incoming request,
+// a request id is generated if there is none, it is for middlewares of other frameworks
+func RequestFields(header http.Header) logrus.Fields {
+	return requestFields(header, requestID(header))
+}
+
+// propagateRequestFields sets request id and traceparent headers of an outbound request by fields of a request-scoped entry,
+// a new span id is generated as the parent of the outbound request and trace flags of the entry are kept
+func propagateRequestFields(header http.Header, fields logrus.Fields) {
+	if rid, ok := fields[FieldRequestID].(string); ok && len(header.Get(HeaderRequestID)) == 0 {
+		header.Set(HeaderRequestID, rid)
+	}
+
+	if traceID, ok := fields[FieldTraceID].(string); ok && len(header.Get(HeaderTraceparent)) == 0 {
+		flags, _ := fields[FieldTraceFlags].(string)
+		if !validTraceFlags(flags) {
+			flags = defaultTraceFlags
+		}
+		header.Set(HeaderTraceparent, "00-"+traceID+"-"+newSpanID()+"-"+flags)
+	}
+}
+
+// PropagationHeader returns request id and traceparent headers to propagate to upstreams
+// by the request-scoped entry carried by ctx, it is for clients of other frameworks
+func PropagationHeader(ctx context.Context) http.Header {
+	header := make(http.Header, 2)
+	if entry, ok := contextEntry(ctx); ok {
+		propagateRequestFields(header, entry.Data)
+	}
+	return header
+}
@@ -0,0 +1,121 @@
+package qlog
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	const (
+		traceID = "4bf92f3577b34da6a3ce929b0e0e4736"
+		spanID  = "00f067aa0ba902b7"
+	)
+
+	tests := []struct {
+		name   string
+		header string
+		ok     bool
+	}{
+		{"valid", "00-" + traceID + "-" + spanID + "-01", true},
+		{"not sampled", "00-" + traceID + "-" + spanID + "-00", true},
+		{"spaces", " 00-" + traceID + "-" + spanID + "-01 ", true},
+		{"future version", "cc-" + traceID + "-" + spanID + "-01-what-the-future-will-be-like", true},
+		{"empty", "", false},
+		{"invalid version ff", "ff-" + traceID + "-" + spanID + "-01", false},
+		{"invalid version hex", "0g-" + traceID + "-" + spanID + "-01", false},
+		{"version length", "000-" + traceID + "-" + spanID + "-01", false},
+		{"version 00 with extra parts", "00-" + traceID + "-" + spanID + "-01-extra", false},
+		{"missing flags", "00-" + traceID + "-" + spanID, false},
+		{"zero trace id", "00-00000000000000000000000000000000-" + spanID + "-01", false},
+		{"zero span id", "00-" + traceID + "-0000000000000000-01", false},
+		{"short trace id", "00-" + traceID[1:] + "-" + spanID + "-01", false},
+		{"long trace id", "00-" + traceID + "0-" + spanID + "-01", false},
+		{"short span id", "00-" + traceID + "-" + spanID[1:] + "-01", false},
+		{"long span id", "00-" + traceID + "-" + spanID + "0-01", false},
+		{"flags length", "00-" + traceID + "-" + spanID + "-1", false},
+		{"uppercase trace id", "00-4BF92F3577B34DA6A3CE929B0E0E4736-" + spanID + "-01", false},
+		{"uppercase span id", "00-" + traceID + "-00F067AA0BA902B7-01", false},
+		{"uppercase flags", "00-" + traceID + "-" + spanID + "-0A", false},
+		{"non hex trace id", "00-" + traceID[:31] + "x-" + spanID + "-01", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotTrace, gotSpan, flags, ok := parseTraceparent(tt.header)
+			if ok != tt.ok {
+				t.Fatalf("parseTraceparent(%q): got ok %v, want %v", tt.header, ok, tt.ok)
+			}
+			if ok && (gotTrace != traceID || gotSpan != spanID || flags != strings.TrimSpace(tt.header)[53:55]) {
+				t.Errorf("parseTraceparent(%q): got %s %s %s, want %s %s", tt.header, gotTrace, gotSpan, flags, traceID, spanID)
+			}
+		})
+	}
+}
+
+func TestPropagateRequestFields(t *testing.T) {
+	const traceID = "4bf92f3577b34da6a3ce929b0e0e4736"
+
+	tests := []struct {
+		name   string
+		fields logrus.Fields
+		flags  string
+	}{
+		{"sampled", logrus.Fields{FieldTraceID: traceID, FieldTraceFlags: "01"}, "01"},
+		{"not sampled", logrus.Fields{FieldTraceID: traceID, FieldTraceFlags: "00"}, "00"},
+		{"no flags", logrus.Fields{FieldTraceID: traceID}, defaultTraceFlags},
+		{"invalid flags", logrus.Fields{FieldTraceID: traceID, FieldTraceFlags: "X"}, defaultTraceFlags},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := make(http.Header)
+			tt.fields[FieldRequestID] = "req-1"
+			propagateRequestFields(header, tt.fields)
+
+			if got := header.Get(HeaderRequestID); got != "req-1" {
+				t.Errorf("request id: got %q, want req-1", got)
+			}
+			gotTrace, spanID, flags, ok := parseTraceparent(header.Get(HeaderTraceparent))
+			if !ok || gotTrace != traceID || flags != tt.flags {
+				t.Errorf("traceparent: got %q, want trace id %s and flags %s", header.Get(HeaderTraceparent), traceID, tt.flags)
+			}
+			if spanID == "00f067aa0ba902b7" {
+				t.Errorf("span id of the incoming request is propagated")
+			}
+		})
+	}
+}
+
+func TestRequestFieldsRoundTrip(t *testing.T) {
+	in := make(http.Header)
+	in.Set(HeaderTraceparent, "00-4bf92f3577b34da6a3ce929b0e0e4736-00f067aa0ba902b7-00")
+	fields := RequestFields(in)
+
+	out := make(http.Header)
+	propagateRequestFields(out, fields)
+	if tp := out.Get(HeaderTraceparent); !strings.HasPrefix(tp, "00-4bf92f3577b34da6a3ce929b0e0e4736-") || !strings.HasSuffix(tp, "-00") {
+		t.Errorf("traceparent: got %q, want the incoming trace id and flags 00", tp)
+	}
+}
+
+func TestPropagationHeaderGinContext(t *testing.T) {
+	entry := logrus.WithFields(logrus.Fields{FieldRequestID: "req-1", FieldTraceID: "4bf92f3577b34da6a3ce929b0e0e4736"})
+
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	c.Set(ginKeyEntry, entry)
+
+	for name, ctx := range map[string]context.Context{"gin.Context": c, "context": NewContext(context.Background(), entry)} {
+		header := PropagationHeader(ctx)
+		if header.Get(HeaderRequestID) != "req-1" || len(header.Get(HeaderTraceparent)) == 0 {
+			t.Errorf("%s: headers are not propagated: %v", name, header)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package qlog
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// field names of outbound requests
+const (
+	FieldURL     = "url"
+	FieldAttempt = "attempt"
+)
+
+const (
+	defaultRetryBackoff = 100 * time.Millisecond
+	maxDrainLength      = 4096
+)
+
+// RoundTripperOptions are options of RoundTripper
+type RoundTripperOptions struct {
+	// Logger is the base logger, default is qlog default logger with field pkg=http-client,
+	// fields of the request-scoped entry in the request context are added
+	Logger *logrus.Entry
+
+	// StatusLevel maps response status to level, default is DefaultStatusLevel, transport errors are logged at error level
+	StatusLevel func(status int) logrus.Level
+
+	// RedactQuery are query parameters whose values are masked in field url, "*" masks all of them
+	RedactQuery []string
+
+	// Retries is the max retries of idempotent requests on transport errors and 502, 503, 504 responses, default is 0
+	Retries int
+	// RetryBackoff is the wait before the first retry, it is doubled for each retry, default is 100ms
+	RetryBackoff time.Duration
+
+	// Body logs request and response bodies as fields reqBody and rspBody, an entry is logged when its response body is closed
+	Body bool
+	BodyCaptureConfig
+}
+
+type roundTripper struct {
+	next     http.RoundTripper
+	opts     RoundTripperOptions
+	bodyConf BodyCaptureConfig
+	mask     string
+}
+
+// RoundTripper logs outbound requests sent by next, http.DefaultTransport is used if next is nil.
+// Request id and traceparent of the request-scoped entry in the request context are propagated.
+func RoundTripper(next http.RoundTripper, opts RoundTripperOptions) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if opts.Logger == nil {
+		opts.Logger = logrus.StandardLogger().WithField("pkg", "http-client")
+	}
+	if opts.StatusLevel == nil {
+		opts.StatusLevel = DefaultStatusLevel
+	}
+	if opts.RetryBackoff <= 0 {
+		opts.RetryBackoff = defaultRetryBackoff
+	}
+
+	return &roundTripper{
+		next:     next,
+		opts:     opts,
+		bodyConf: opts.BodyCaptureConfig.withDefaults(),
+		mask:     v.GetString(keyRedactMask),
+	}
+}
+
+// redactURL masks values of query parameters in names and the password of u
+func redactURL(u *url.URL, names []string, mask string) string {
+	if len(names) == 0 || len(u.RawQuery) == 0 {
+		return u.Redacted()
+	}
+
+	params := strings.Split(u.RawQuery, "&")
+	for i, param := range params {
+		key, _, _ := strings.Cut(param, "=")
+		if name, err := url.QueryUnescape(key); err == nil {
+			key = name
+		}
+
+		for _, name := range names {
+			if name == "*" || strings.EqualFold(name, key) {
+				params[i] = param[:strings.IndexByte(param+"=", '=')] + "=" + mask
+				break
+			}
+		}
+	}
+
+	ru := *u
+	ru.RawQuery = strings.Join(params, "&")
+	return ru.Redacted()
+}
+
+// retryable checks if a request is idempotent and its body can be sent again
+func retryable(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+	default:
+		if len(req.Header.Get("Idempotency-Key")) == 0 {
+			return false
+		}
+	}
+
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+func shouldRetry(req *http.Request, resp *http.Response, err error) bool {
+	if err != nil {
+		return req.Context().Err() == nil
+	}
+
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func (t *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	entry := t.opts.Logger
+	header := req.Header.Clone()
+
+	if ctxEntry, ok := contextEntry(ctx); ok {
+		entry = entry.WithFields(ctxEntry.Data)
+		propagateRequestFields(header, ctxEntry.Data)
+	}
+
+	entry = entry.WithContext(ctx).WithFields(logrus.Fields{
+		FieldMethod: req.Method,
+		FieldURL:    redactURL(req.URL, t.opts.RedactQuery, t.mask),
+	})
+
+	retries := 0
+	if t.opts.Retries > 0 && retryable(req) {
+		retries = t.opts.Retries
+	}
+	backoff := t.opts.RetryBackoff
+
+	for attempt := 1; ; attempt++ {
+		out := req.Clone(ctx)
+		out.Header = header.Clone()
+
+		if attempt > 1 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				out.Body = body
+			}
+		}
+
+		last := attempt > retries
+		resp, err := t.roundTrip(entry, out, attempt, last)
+		if last || !shouldRetry(out, resp, err) {
+			return resp, err
+		}
+
+		if resp != nil {
+			io.CopyN(io.Discard, resp.Body, maxDrainLength)
+			resp.Body.Close()
+		}
+	}
+}
+
+// roundTrip sends a request once and logs it
+func (t *roundTripper) roundTrip(entry *logrus.Entry, req *http.Request, attempt int, last bool) (*http.Response, error) {
+	var reqBody *bodyCapture
+	if t.opts.Body {
+		reqBody = newBodyCapture(t.bodyConf, req.Header)
+		if req.Body != nil && req.Body != http.NoBody {
+			req.Body = &captureReadCloser{req.Body, reqBody}
+		}
+	}
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	latency := int(math.Ceil(float64(time.Since(start).Nanoseconds()) / 1000000.0))
+
+	fields := logrus.Fields{FieldLatency: latency}
+	if t.opts.Retries > 0 {
+		fields[FieldAttempt] = attempt
+	}
+	if reqBody != nil {
+		reqBody.setFields(fields, FieldReqBody, FieldReqLength, FieldReqContentType, int(req.ContentLength))
+	}
+
+	if err != nil {
+		level := logrus.ErrorLevel
+		if !last {
+			level = logrus.WarnLevel
+		}
+		entry.WithFields(fields).Log(level, fmt.Sprintf("\"%s %s\" (%dms) fail:%s", req.Method, entry.Data[FieldURL], latency, err))
+		return nil, err
+	}
+
+	fields[FieldStatusCode] = resp.StatusCode
+	level := t.opts.StatusLevel(resp.StatusCode)
+	msg := fmt.Sprintf("%d \"%s %s\" (%dms)", resp.StatusCode, req.Method, entry.Data[FieldURL], latency)
+
+	if !t.opts.Body || resp.StatusCode == http.StatusSwitchingProtocols || !entry.Logger.IsLevelEnabled(level) {
+		entry.WithFields(fields).Log(level, msg)
+		return resp, nil
+	}
+
+	rspBody := newBodyCapture(t.bodyConf, resp.Header)
+	resp.Body = &loggedBody{
+		captureReadCloser: captureReadCloser{resp.Body, rspBody},
+		log: func() {
+			rspBody.setFields(fields, FieldRspBody, FieldRspLength, FieldRspContentType, int(resp.ContentLength))
+			entry.WithFields(fields).Log(level, msg)
+		},
+	}
+
+	return resp, nil
+}
+
+// loggedBody captures a response body and logs the request when it is closed
+type loggedBody struct {
+	captureReadCloser
+	once sync.Once
+	log  func()
+}
+
+func (b *loggedBody) Close() error {
+	err := b.captureReadCloser.Close()
+	b.once.Do(b.log)
+	return err
+}
@@ -0,0 +1,179 @@
+package qlog
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	keySamplingLevels  = "logger.sampling.levels"
+	keySamplingSummary = "logger.sampling.summary"
+
+	defaultSamplingInterval = time.Second
+
+	// max keys counted by a window in an interval, entries of other keys are counted together
+	maxSamplingKeys = 10000
+	// key of entries counted together when a window has maxSamplingKeys keys
+	samplingOverflowKey = ""
+)
+
+// samplingRule logs the First entries with the same message and caller in every Interval,
+// and then every Thereafter entry, 0 Thereafter drops all the others
+type samplingRule struct {
+	First      uint64
+	Thereafter uint64
+	Interval   time.Duration
+}
+
+// samplingWindow counts entries of a level by message and caller in an interval
+type samplingWindow struct {
+	rule   samplingRule
+	end    time.Time
+	counts map[string]uint64
+}
+
+func (w *samplingWindow) allowed(key string, now time.Time) bool {
+	if now.After(w.end) {
+		w.end = now.Add(w.rule.Interval)
+		w.counts = make(map[string]uint64)
+	}
+
+	// messages with varying values, e.g. ids, should not grow the map without limit
+	if _, ok := w.counts[key]; !ok && len(w.counts) >= maxSamplingKeys {
+		key = samplingOverflowKey
+	}
+
+	n := w.counts[key] + 1
+	w.counts[key] = n
+
+	if n <= w.rule.First {
+		return true
+	}
+	return w.rule.Thereafter > 0 && (n-w.rule.First)%w.rule.Thereafter == 0
+}
+
+// sampler drops repetitive entries before hooks fire, and logs the number of dropped entries periodically
+type sampler struct {
+	mu      sync.Mutex
+	windows map[logrus.Level]*samplingWindow
+
+	summary    time.Duration
+	suppressed atomic.Uint64 // since last summary
+	stop       chan struct{}
+}
+
+func newSampler(rules map[logrus.Level]samplingRule, summary time.Duration) *sampler {
+	s := &sampler{
+		windows: make(map[logrus.Level]*samplingWindow, len(rules)),
+		summary: summary,
+		stop:    make(chan struct{}),
+	}
+
+	for level, rule := range rules {
+		if rule.Interval <= 0 {
+			rule.Interval = defaultSamplingInterval
+		}
+		s.windows[level] = &samplingWindow{rule: rule}
+	}
+
+	return s
+}
+
+// start starts the summary loop, summaries are fired to hooks by fire, so that they are not filtered or sampled
+func (s *sampler) start(fire func(e *logrus.Entry)) {
+	if s.summary > 0 {
+		go s.summaryLoop(s.summary, fire)
+	}
+}
+
+// allowed counts e by message and caller, the caller is looked up with callerSkips only for sampled levels
+func (s *sampler) allowed(e *logrus.Entry, callerSkips []string) bool {
+	w, ok := s.windows[e.Level]
+	if !ok {
+		return true
+	}
+
+	key := e.Message
+	if caller := entryCaller(e, callerSkips); caller != nil {
+		key = caller.File + ":" + strconv.Itoa(caller.Line) + " " + key
+	}
+
+	s.mu.Lock()
+	allowed := w.allowed(key, time.Now())
+	s.mu.Unlock()
+
+	if !allowed {
+		s.suppressed.Add(1)
+		gSuppressedCount.Add(1)
+	}
+	return allowed
+}
+
+func (s *sampler) summaryLoop(period time.Duration, fire func(e *logrus.Entry)) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			if n := s.suppressed.Swap(0); n > 0 {
+				e := qLogger.WithField("suppressed", n).WithTime(time.Now())
+				e.Level = logrus.WarnLevel
+				e.Message = fmt.Sprintf("log sampling suppressed %d entries in last %s", n, period)
+				fire(e)
+			}
+		}
+	}
+}
+
+// close stops the summary loop, s can be nil
+func (s *sampler) close() {
+	if s != nil {
+		close(s.stop)
+	}
+}
+
+// getSamplingRules returns empty rules if sampling is not configured
+func getSamplingRules() (rules map[logrus.Level]samplingRule, summary time.Duration, err error) {
+	var levels map[string]samplingRule
+
+	if err = v.UnmarshalKey(keySamplingLevels, &levels); err != nil {
+		return nil, 0, fmt.Errorf("parse %s fail: %s", keySamplingLevels, err)
+	}
+
+	rules = make(map[logrus.Level]samplingRule, len(levels))
+	for name, rule := range levels {
+		level, err := logrus.ParseLevel(name)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%s.%s: %s", keySamplingLevels, name, err)
+		}
+		if level <= logrus.FatalLevel {
+			return nil, 0, fmt.Errorf("%s.%s: %s entries can't be sampled", keySamplingLevels, name, level)
+		}
+		rules[level] = rule
+	}
+
+	if summary, err = time.ParseDuration(v.GetString(keySamplingSummary)); err != nil {
+		return nil, 0, fmt.Errorf("parse %s fail: %s", keySamplingSummary, err)
+	}
+
+	return rules, summary, nil
+}
+
+func checkSampling(key string) error {
+	_, _, err := getSamplingRules()
+	return err
+}
+
+var _InitSampler = func() interface{} {
+	registerConfigKey(keySamplingLevels+".", checkSampling)
+	registerConfigKey(keySamplingSummary, checkDuration)
+	return nil
+}()
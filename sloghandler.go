@@ -0,0 +1,161 @@
+package qlog
+
+import (
+	"context"
+	"io"
+	"log"
+	"log/slog"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	keySlogDefault = "logger.slog.default"
+)
+
+// SlogHandler is a slog.Handler which routes records through qlog hooks and formatters
+type SlogHandler struct {
+	entry  *logrus.Entry
+	prefix string // group prefix of attr keys, e.g. "g1.g2."
+}
+
+// NewSlogHandler creates a SlogHandler, records are logged by qlog default logger if logger is not set
+func NewSlogHandler(logger ...*logrus.Entry) *SlogHandler {
+	if len(logger) == 0 {
+		return &SlogHandler{entry: logrus.NewEntry(qLogger)}
+	}
+
+	return &SlogHandler{entry: logger[0]}
+}
+
+// slogLevelToLogrus maps slog level to logrus level, levels lower than slog.LevelDebug are trace
+func slogLevelToLogrus(level slog.Level) logrus.Level {
+	switch {
+	case level < slog.LevelDebug:
+		return logrus.TraceLevel
+	case level < slog.LevelInfo:
+		return logrus.DebugLevel
+	case level < slog.LevelWarn:
+		return logrus.InfoLevel
+	case level < slog.LevelError:
+		return logrus.WarnLevel
+	}
+	return logrus.ErrorLevel
+}
+
+// Enabled reports whether the logger of the handler logs the level
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.entry.Logger.IsLevelEnabled(slogLevelToLogrus(level))
+}
+
+// Handle logs the record, attrs become entry fields and record source becomes entry caller
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	fields := make(logrus.Fields, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		addSlogAttr(fields, h.prefix, a)
+		return true
+	})
+
+	if r.PC != 0 {
+		ctx = withCaller(ctx, callerFromPC(r.PC))
+	}
+
+	h.entry.WithContext(ctx).WithTime(r.Time).WithFields(fields).Log(slogLevelToLogrus(r.Level), r.Message)
+
+	return nil
+}
+
+// WithAttrs returns a new handler whose entry has attrs as fields
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+
+	fields := make(logrus.Fields, len(attrs))
+	for _, a := range attrs {
+		addSlogAttr(fields, h.prefix, a)
+	}
+
+	return &SlogHandler{entry: h.entry.WithFields(fields), prefix: h.prefix}
+}
+
+// WithGroup returns a new handler whose attr keys are qualified by name, e.g. "name.key"
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	return &SlogHandler{entry: h.entry, prefix: h.prefix + name + "."}
+}
+
+func addSlogAttr(fields logrus.Fields, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+
+	// ignore empty attrs as slog.Handler requires
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		groupPrefix := prefix
+		if a.Key != "" {
+			groupPrefix = prefix + a.Key + "."
+		}
+
+		for _, ga := range a.Value.Group() {
+			addSlogAttr(fields, groupPrefix, ga)
+		}
+		return
+	}
+
+	fields[prefix+a.Key] = a.Value.Any()
+}
+
+var (
+	gSlogMu      sync.Mutex
+	gSlogDefault *slog.Logger // the default logger set by setupSlog, nil if it is not set
+
+	// slog default logger and log package output before setupSlog sets the default logger
+	gSlogPrevDefault *slog.Logger
+	gLogPrevWriter   io.Writer
+	gLogPrevFlags    int
+)
+
+// setupSlog sets the slog default logger if the bridge is enabled, or restores the previous default logger
+// and log package output if the bridge is disabled by a reload and the default logger is not changed by others
+func setupSlog() {
+	gSlogMu.Lock()
+	defer gSlogMu.Unlock()
+
+	if v.GetBool(keySlogDefault) {
+		if gSlogDefault == nil {
+			gSlogPrevDefault = slog.Default()
+			gLogPrevWriter, gLogPrevFlags = log.Writer(), log.Flags()
+		}
+		gSlogDefault = slog.New(NewSlogHandler())
+		slog.SetDefault(gSlogDefault)
+		return
+	}
+
+	if gSlogDefault == nil {
+		return
+	}
+
+	if slog.Default() == gSlogDefault {
+		// slog.SetDefault doesn't restore the log package output when the default handler is restored
+		slog.SetDefault(gSlogPrevDefault)
+		log.SetOutput(gLogPrevWriter)
+		log.SetFlags(gLogPrevFlags)
+	}
+	gSlogDefault, gSlogPrevDefault, gLogPrevWriter = nil, nil, nil
+}
+
+var _InitSlogHandler = func() interface{} {
+	cli.Bool(keySlogDefault, false, "logger.slog.default")
+	return nil
+}()
@@ -0,0 +1,107 @@
+package qlog
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"log/slog"
+	"runtime"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	maxLineLength = 64 * 1024 // a partial line longer than this is logged without waiting for '\n'
+)
+
+// packages skipped when looking for the caller of a redirected line
+var gLineWriterSkipPkgs = []string{gPkgPath, "log", "log/slog", "fmt", "io", "bufio", "github.com/gin-gonic/gin"}
+
+// lineWriter is an io.Writer which logs every written line as an entry
+type lineWriter struct {
+	entry *logrus.Entry
+	level logrus.Level
+
+	mu  sync.Mutex
+	buf []byte
+}
+
+// NewWriter returns an io.Writer which logs each written line at level with field pkg
+func NewWriter(pkg string, level logrus.Level) io.Writer {
+	return &lineWriter{
+		entry: qLogger.WithField("pkg", pkg),
+		level: level,
+	}
+}
+
+// NewStdLogger returns a standard library *log.Logger which logs through qlog, e.g. for http.Server.ErrorLog
+func NewStdLogger(pkg string, level logrus.Level) *log.Logger {
+	return log.New(NewWriter(pkg, level), "", 0)
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	var lines [][]byte
+
+	w.mu.Lock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		lines = append(lines, bytes.TrimRight(w.buf[:i], "\r"))
+		w.buf = w.buf[i+1:]
+	}
+	if len(w.buf) > maxLineLength {
+		lines = append(lines, w.buf)
+		w.buf = nil
+	}
+	if len(w.buf) == 0 {
+		w.buf = nil // release the backing array of logged lines
+	}
+	w.mu.Unlock()
+
+	// log outside the lock, a hook may write to this writer again
+	if len(lines) > 0 {
+		entry := w.entry
+		if caller := externalCaller(gLineWriterSkipPkgs); caller != nil {
+			entry = entry.WithContext(withCaller(context.Background(), caller))
+		}
+
+		for _, line := range lines {
+			if len(line) > 0 {
+				entry.Log(w.level, string(line))
+			}
+		}
+	}
+
+	return len(p), nil
+}
+
+// externalCaller returns the first frame of the calling stack which is not in skipPkgs
+func externalCaller(skipPkgs []string) *runtime.Frame {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	for {
+		frame, more := frames.Next()
+		if !funcInPackages(frame.Function, skipPkgs) {
+			return &frame
+		}
+		if !more {
+			return nil
+		}
+	}
+}
+
+// CaptureStdLog redirects the standard log package output at level, and slog.Default() to qlog
+func CaptureStdLog(level logrus.Level) {
+	// slog.SetDefault redirects the log package to slog, so it must be called before log.SetOutput
+	slog.SetDefault(slog.New(NewSlogHandler(qLogger.WithField("pkg", "slog"))))
+
+	log.SetOutput(NewWriter("log", level))
+	log.SetFlags(0) // time is added by formatters
+}
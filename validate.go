@@ -0,0 +1,273 @@
+package qlog
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cast"
+	"github.com/spf13/pflag"
+)
+
+const (
+	sourceFlag    = "flag"
+	sourceEnv     = "env"
+	sourceFile    = "file"
+	sourceDefault = "default"
+)
+
+// ConfigError is a logger config problem found by Validate
+type ConfigError struct {
+	Key string
+	Err error
+}
+
+func (e *ConfigError) Error() string {
+	return e.Key + ": " + e.Err.Error()
+}
+
+// ConfigValue is an effective logger config value and where it comes from
+type ConfigValue struct {
+	Key    string
+	Value  interface{}
+	Source string // flag, env, file or default
+}
+
+var (
+	errUnknownKey = errors.New("unknown key")
+
+	// known config keys which are not flags, with an optional value checker
+	gConfigKeys = map[string]func(key string) error{
+		keyLevels: nil, // checked by Validate
+	}
+)
+
+// registerConfigKey registers a known config key and its value checker for Validate, flags are known by default.
+// A key ending with "." is a prefix of known keys, its checker is called once with the prefix if any key is set.
+func registerConfigKey(key string, check func(key string) error) {
+	gConfigKeys[key] = check
+}
+
+func checkLevel(key string) error {
+	if l := v.GetString(key); l != "" {
+		_, err := logrus.ParseLevel(l)
+		return err
+	}
+	return nil
+}
+
+func checkDuration(key string) error {
+	_, err := time.ParseDuration(v.GetString(key))
+	return err
+}
+
+func checkUint(key string) error {
+	_, err := cast.ToUintE(v.Get(key))
+	return err
+}
+
+func checkFlagValue(f *pflag.Flag) error {
+	var err error
+	switch f.Value.Type() {
+	case "bool":
+		_, err = cast.ToBoolE(v.Get(f.Name))
+	case "int":
+		_, err = cast.ToIntE(v.Get(f.Name))
+	}
+	return err
+}
+
+// formatterOptsKeys returns known opts keys of a formatter
+func formatterOptsKeys(name string) map[string]bool {
+	keys := map[string]bool{keyPrettyCaller: true}
+
+	if typ, ok := gRegisteredFormatters[name]; ok {
+		for i := 0; i < typ.NumField(); i++ {
+			if f := typ.Field(i); f.IsExported() {
+				keys[strings.ToLower(f.Name)] = true
+			}
+		}
+	}
+
+	return keys
+}
+
+// validateFormatter checks formatter config at prefix, e.g. logger.file.formatter
+func validateFormatter(prefix string, allKeys []string, defaultName string) (errs []error) {
+	nameKey := prefix + ".name"
+	optsKey := prefix + ".opts"
+
+	name := v.GetString(nameKey)
+	if name == "" {
+		name = defaultName
+	}
+
+	if prettyCaller := v.GetString(optsKey + "." + keyPrettyCaller); len(prettyCaller) > 0 {
+		if _, err := getCallerPrettyfier(prettyCaller); err != nil {
+			errs = append(errs, &ConfigError{Key: optsKey + "." + keyPrettyCaller, Err: err})
+		}
+	}
+
+	// newFormatter fails for unregistered prettyfiers too, they are reported once
+	if len(name) > 0 && len(errs) == 0 {
+		if _, err := newFormatter(name, optsKey); err != nil {
+			errs = append(errs, &ConfigError{Key: prefix, Err: err})
+		}
+	}
+
+	known := formatterOptsKeys(name)
+	for _, key := range allKeys {
+		if !strings.HasPrefix(key, optsKey+".") {
+			continue
+		}
+		if len(name) == 0 {
+			errs = append(errs, &ConfigError{Key: key, Err: errors.New("formatter opts without formatter name are ignored")})
+			continue
+		}
+		opt := strings.SplitN(strings.TrimPrefix(key, optsKey+"."), ".", 2)[0]
+		if !known[opt] {
+			errs = append(errs, &ConfigError{Key: key, Err: fmt.Errorf("unknown option of formatter(%s)", name)})
+		}
+	}
+
+	return
+}
+
+// Validate checks the loaded logger config, it reports unknown keys and invalid levels, durations and formatters
+func Validate() []error {
+	var errs []error
+
+	allKeys := loggerKeys()
+	known := make(map[string]func(string) error, len(gConfigKeys))
+	for key, check := range gConfigKeys {
+		known[key] = check
+	}
+	cli.VisitAll(func(f *pflag.Flag) {
+		if _, ok := known[f.Name]; !ok {
+			known[f.Name] = nil
+		}
+		if err := checkFlagValue(f); err != nil {
+			errs = append(errs, &ConfigError{Key: f.Name, Err: err})
+		}
+	})
+
+	formatterPrefixes := []string{"logger.formatter"}
+	for _, name := range registeredHookNames() {
+		prefix := strings.Join([]string{"logger", name}, ".")
+		known[prefix+".enabled"] = nil
+		known[prefix+".level"] = checkLevel
+		known[prefix+".formatter.name"] = nil
+		formatterPrefixes = append(formatterPrefixes, prefix+".formatter")
+	}
+
+	prefixes := make(map[string]bool)
+	for _, key := range allKeys {
+		check, ok := known[key]
+		if !ok {
+			if prefix := knownPrefix(key, known); len(prefix) > 0 {
+				prefixes[prefix] = true
+			} else if !isFormatterOptsKey(key, formatterPrefixes) {
+				errs = append(errs, &ConfigError{Key: key, Err: errUnknownKey})
+			}
+			continue
+		}
+		if check != nil {
+			if err := check(key); err != nil {
+				errs = append(errs, &ConfigError{Key: key, Err: err})
+			}
+		}
+	}
+
+	for prefix := range prefixes {
+		if check := known[prefix]; check != nil {
+			if err := check(prefix); err != nil {
+				errs = append(errs, &ConfigError{Key: strings.TrimSuffix(prefix, "."), Err: err})
+			}
+		}
+	}
+
+	if overrides, err := getLevelOverrides(); err != nil {
+		errs = append(errs, &ConfigError{Key: keyLevels, Err: err})
+	} else if err = parseLevelOverrides(overrides); err != nil {
+		errs = append(errs, &ConfigError{Key: keyLevels, Err: err})
+	}
+
+	defaultName := v.GetString(keyDefaultFormatterName)
+	for i, prefix := range formatterPrefixes {
+		if i == 0 {
+			errs = append(errs, validateFormatter(prefix, allKeys, defaultName)...)
+		} else {
+			errs = append(errs, validateFormatter(prefix, allKeys, "")...)
+		}
+	}
+
+	return errs
+}
+
+func knownPrefix(key string, known map[string]func(string) error) string {
+	for prefix := range known {
+		if strings.HasSuffix(prefix, ".") && strings.HasPrefix(key, prefix) {
+			return prefix
+		}
+	}
+	return ""
+}
+
+func isFormatterOptsKey(key string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(key, prefix+".opts.") {
+			return true
+		}
+	}
+	return false
+}
+
+// loggerKeys returns all sorted keys under logger
+func loggerKeys() []string {
+	keys := make([]string, 0)
+	for _, key := range v.AllKeys() {
+		if strings.HasPrefix(key, "logger.") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func configSource(key string) string {
+	if f := cli.Lookup(key); f != nil && f.Changed {
+		return sourceFlag
+	}
+	if _, ok := os.LookupEnv(strings.ToUpper(gEnvKeyReplacer.Replace(key))); ok {
+		return sourceEnv
+	}
+	if v.InConfig(key) {
+		return sourceFile
+	}
+	return sourceDefault
+}
+
+// EffectiveConfig returns the merged logger config with the source of each value
+func EffectiveConfig() []ConfigValue {
+	keys := loggerKeys()
+	values := make([]ConfigValue, 0, len(keys))
+
+	for _, key := range keys {
+		values = append(values, ConfigValue{
+			Key:    key,
+			Value:  v.Get(key),
+			Source: configSource(key),
+		})
+	}
+
+	return values
+}
+
+// ConfigFileUsed returns the logger config file, empty if no config file is found
+func ConfigFileUsed() string {
+	return v.ConfigFileUsed()
+}